@@ -0,0 +1,106 @@
+// Package aggregate summarizes daily series by week, month, quarter or year.
+package aggregate
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Frequency is the length of the periods.
+type Frequency string
+
+const (
+	Weekly    Frequency = "weekly"
+	Monthly   Frequency = "monthly"
+	Quarterly Frequency = "quarterly"
+	Annual    Frequency = "annual"
+)
+
+// Frequencies are all the frequencies, from the shortest.
+var Frequencies = []Frequency{Weekly, Monthly, Quarterly, Annual}
+
+// Observation is the value of a series at a date.
+type Observation struct {
+	Date  time.Time
+	Value float64
+}
+
+// Stats summarize the observations of a period.
+type Stats struct {
+	// Start and End are the first and last days of the period, not of its observations.
+	Start, End time.Time
+	Count      int
+	Average    float64
+	// Last is the value of the last observation of the period, made on LastDate.
+	Last     float64
+	LastDate time.Time
+	Min, Max float64
+}
+
+// Start returns the first day of the period of date: the Monday of its week, the first day
+// of its month, quarter or year.
+func (f Frequency) Start(date time.Time) time.Time {
+	y, m, d := date.Date()
+	switch f {
+	case Weekly:
+		offset := (int(date.Weekday()) + 6) % 7
+		return time.Date(y, m, d-offset, 0, 0, 0, 0, date.Location())
+	case Monthly:
+		return time.Date(y, m, 1, 0, 0, 0, 0, date.Location())
+	case Quarterly:
+		return time.Date(y, m-(m-1)%3, 1, 0, 0, 0, 0, date.Location())
+	}
+	return time.Date(y, time.January, 1, 0, 0, 0, 0, date.Location())
+}
+
+// End returns the last day of the period starting on start.
+func (f Frequency) End(start time.Time) time.Time {
+	switch f {
+	case Weekly:
+		return start.AddDate(0, 0, 6)
+	case Monthly:
+		return start.AddDate(0, 1, -1)
+	case Quarterly:
+		return start.AddDate(0, 3, -1)
+	}
+	return start.AddDate(1, 0, -1)
+}
+
+// Parse returns the frequency named s.
+func Parse(s string) (Frequency, error) {
+	for _, f := range Frequencies {
+		if string(f) == s {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("invalid frequency %q", s)
+}
+
+// Aggregate returns the stats of each period with observations, in order. Periods without
+// observations are left out rather than filled, the stats only come from actual observations.
+func Aggregate(obs []Observation, f Frequency) []Stats {
+	sorted := append([]Observation{}, obs...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Date.Before(sorted[j].Date) })
+	var stats []Stats
+	for _, o := range sorted {
+		start := f.Start(o.Date)
+		if len(stats) == 0 || !stats[len(stats)-1].Start.Equal(start) {
+			stats = append(stats, Stats{Start: start, End: f.End(start), Min: o.Value, Max: o.Value})
+		}
+		s := &stats[len(stats)-1]
+		s.Average += o.Value
+		s.Count++
+		s.Last, s.LastDate = o.Value, o.Date
+		if o.Value < s.Min {
+			s.Min = o.Value
+		}
+		if o.Value > s.Max {
+			s.Max = o.Value
+		}
+	}
+	for i := range stats {
+		stats[i].Average /= float64(stats[i].Count)
+	}
+	return stats
+}
@@ -0,0 +1,60 @@
+package aggregate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func Test_Start(t *testing.T) {
+	a := assert.New(t)
+	thursday := date(2023, time.June, 8)
+	a.Equal(date(2023, time.June, 5), Weekly.Start(thursday))
+	a.Equal(date(2023, time.June, 11), Weekly.End(Weekly.Start(thursday)))
+	a.Equal(date(2023, time.June, 5), Weekly.Start(date(2023, time.June, 5)), "monday")
+	a.Equal(date(2023, time.June, 5), Weekly.Start(date(2023, time.June, 11)), "sunday")
+	a.Equal(date(2023, time.June, 1), Monthly.Start(thursday))
+	a.Equal(date(2023, time.June, 30), Monthly.End(Monthly.Start(thursday)))
+	a.Equal(date(2023, time.April, 1), Quarterly.Start(thursday))
+	a.Equal(date(2023, time.June, 30), Quarterly.End(Quarterly.Start(thursday)))
+	a.Equal(date(2023, time.October, 1), Quarterly.Start(date(2023, time.December, 31)))
+	a.Equal(date(2023, time.January, 1), Annual.Start(thursday))
+	a.Equal(date(2023, time.December, 31), Annual.End(Annual.Start(thursday)))
+}
+
+func Test_Aggregate(t *testing.T) {
+	a := assert.New(t)
+	obs := []Observation{
+		{date(2023, time.May, 31), 4.0},
+		{date(2023, time.May, 29), 3.0},
+		{date(2023, time.June, 1), 3.5},
+		{date(2023, time.June, 2), 4.5},
+		// no observations in July
+		{date(2023, time.August, 1), 5.0},
+	}
+	stats := Aggregate(obs, Monthly)
+	a.Equal([]Stats{
+		{Start: date(2023, time.May, 1), End: date(2023, time.May, 31), Count: 2, Average: 3.5, Last: 4.0, LastDate: date(2023, time.May, 31), Min: 3.0, Max: 4.0},
+		{Start: date(2023, time.June, 1), End: date(2023, time.June, 30), Count: 2, Average: 4.0, Last: 4.5, LastDate: date(2023, time.June, 2), Min: 3.5, Max: 4.5},
+		{Start: date(2023, time.August, 1), End: date(2023, time.August, 31), Count: 1, Average: 5.0, Last: 5.0, LastDate: date(2023, time.August, 1), Min: 5.0, Max: 5.0},
+	}, stats)
+
+	weekly := Aggregate(obs, Weekly)
+	a.Len(weekly, 2)
+	a.Equal(4, weekly[0].Count)
+	a.Equal(3.75, weekly[0].Average)
+
+	a.Len(Aggregate(obs, Annual), 1)
+	a.Empty(Aggregate(nil, Quarterly))
+
+	f, err := Parse("quarterly")
+	a.NoError(err)
+	a.Equal(Quarterly, f)
+	_, err = Parse("daily")
+	a.Error(err)
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/clauderoy790/boc-excel-file-maker/aggregate"
+	"github.com/clauderoy790/boc-excel-file-maker/quality"
+	"github.com/xuri/excelize/v2"
+)
+
+// frequencyTitles name the aggregation sheets, in the order of aggregate.Frequencies.
+var frequencyTitles = map[aggregate.Frequency]string{
+	aggregate.Weekly:    "Hebdomadaire",
+	aggregate.Monthly:   "Mensuel",
+	aggregate.Quarterly: "Trimestriel",
+	aggregate.Annual:    "Annuel",
+}
+
+// frequencyChoices are the titles of the frequencies, as shown in the form.
+func frequencyChoices() []string {
+	var titles []string
+	for _, f := range aggregate.Frequencies {
+		titles = append(titles, frequencyTitles[f])
+	}
+	return titles
+}
+
+var aggregateStats = []string{"moy.", "fin", "min", "max"}
+
+// maxSheetName is the longest sheet name Excel accepts.
+const maxSheetName = 31
+
+func aggregateSheetName(sheet string, f aggregate.Frequency) string {
+	name := sheet + " - " + frequencyTitles[f]
+	if r := []rune(name); len(r) > maxSheetName {
+		name = string(r[:maxSheetName])
+	}
+	return name
+}
+
+// writeAggregateSheets writes a sheet for each daily sheet and chosen frequency with the
+// average, the last value, the minimum and the maximum of each column over each period.
+func writeAggregateSheets(f *excelize.File, opts options, series []*quality.Series) error {
+	var sheets []string
+	bySheet := make(map[string][]*quality.Series)
+	for _, s := range series {
+		if !s.Daily {
+			continue
+		}
+		if _, ok := bySheet[s.Sheet]; !ok {
+			sheets = append(sheets, s.Sheet)
+		}
+		bySheet[s.Sheet] = append(bySheet[s.Sheet], s)
+	}
+	for _, freq := range aggregate.Frequencies {
+		if !contains(opts.aggregates, frequencyTitles[freq]) {
+			continue
+		}
+		for _, sheet := range sheets {
+			if err := writeAggregateSheet(f, aggregateSheetName(sheet, freq), freq, bySheet[sheet]); err != nil {
+				return fmt.Errorf("error writing %s: %w", aggregateSheetName(sheet, freq), err)
+			}
+		}
+	}
+	return nil
+}
+
+func writeAggregateSheet(f *excelize.File, sheet string, freq aggregate.Frequency, series []*quality.Series) error {
+	f.NewSheet(sheet)
+	if err := setCell(f, sheet, "A1", sheet); err != nil {
+		return err
+	}
+	if err := setCell(f, sheet, "A2", "Calculé à partir des observations seulement, les jours sans taux sont exclus"); err != nil {
+		return err
+	}
+	titles := []interface{}{"Début", "Fin"}
+	for _, s := range series {
+		for _, stat := range aggregateStats {
+			titles = append(titles, s.Name+" "+stat)
+		}
+	}
+	if err := setRow(f, sheet, "A5", titles); err != nil {
+		return err
+	}
+
+	// the periods of all the columns, by start date, with the stats of each column
+	type period struct {
+		stats []*aggregate.Stats
+	}
+	periods := make(map[string]*period)
+	var starts []string
+	for i, s := range series {
+		obs := make([]aggregate.Observation, len(s.Observations))
+		for j, o := range s.Observations {
+			obs[j] = aggregate.Observation{Date: o.Date, Value: o.Value}
+		}
+		for _, st := range aggregate.Aggregate(obs, freq) {
+			st := st
+			key := dateString(st.Start)
+			p, ok := periods[key]
+			if !ok {
+				p = &period{stats: make([]*aggregate.Stats, len(series))}
+				periods[key] = p
+				starts = append(starts, key)
+			}
+			p.stats[i] = &st
+		}
+	}
+	sort.Strings(starts)
+	for line, key := range starts {
+		p := periods[key]
+		start := parsePrefDate(key)
+		row := []interface{}{colDateString(start), colDateString(freq.End(start))}
+		for i, st := range p.stats {
+			if st == nil {
+				row = append(row, "n/a", "n/a", "n/a", "n/a")
+				continue
+			}
+			for _, v := range []float64{st.Average, st.Last, st.Min, st.Max} {
+				row = append(row, aggregateValue(v, series[i].Percent))
+			}
+		}
+		if err := setRow(f, sheet, fmt.Sprintf("A%d", 6+line), row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// aggregateValue formats a value like the daily sheets: a rate as a fraction, the others as they are.
+func aggregateValue(v float64, percent bool) string {
+	if percent {
+		return fmt.Sprintf("%.4f", v/100)
+	}
+	return strconv.FormatFloat(v, 'f', 4, 64)
+}
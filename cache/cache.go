@@ -0,0 +1,372 @@
+// Package cache keeps the data fetched by the program in the user cache directory. Each entry is compressed,
+// written atomically and listed with its checksum in a manifest, so the cache can be inspected, verified and purged.
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/clauderoy790/boc-excel-file-maker/provenance"
+)
+
+// appName is the folder of the program in the user cache directory.
+const appName = "boc-excel-file-maker"
+
+const manifestFile = "manifest.json"
+
+// compressedExt ends the names of the files of the entries.
+const compressedExt = ".gz"
+
+// Dir is the folder of the default store, the program's folder in the user cache directory when empty.
+var Dir string
+
+// ErrChecksum is returned for an entry whose content does not match its checksum.
+var ErrChecksum = errors.New("checksum mismatch")
+
+// ErrNotInManifest is reported by Verify for a file of the cache that no entry refers to.
+var ErrNotInManifest = errors.New("file not in the manifest")
+
+// Entry describes a cached piece of data.
+type Entry struct {
+	// Source is the source the data came from, e.g. treasury.
+	Source string `json:"source"`
+	// Key names the data in its source, as a slash separated path.
+	Key string `json:"key"`
+	// Size is the size of the compressed file, RawSize that of the data.
+	Size    int64 `json:"size"`
+	RawSize int64 `json:"rawSize"`
+	// SHA256 is the hex encoded SHA-256 of the data.
+	SHA256   string    `json:"sha256"`
+	StoredAt time.Time `json:"storedAt"`
+	// Kept is true for an entry stored with Keep, which Purge leaves.
+	Kept bool `json:"kept,omitempty"`
+}
+
+func (e Entry) file() string {
+	return filepath.Join(e.Source, filepath.FromSlash(e.Key)) + compressedExt
+}
+
+func id(source, key string) string {
+	return source + "/" + key
+}
+
+// Store is a cache folder and its manifest, it is safe for concurrent use.
+type Store struct {
+	dir     string
+	mu      sync.Mutex
+	entries map[string]Entry
+}
+
+// DefaultDir returns the folder of the default store.
+func DefaultDir() (string, error) {
+	if Dir != "" {
+		return Dir, nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("unable to get the user cache directory: %w", err)
+	}
+	return filepath.Join(dir, appName), nil
+}
+
+var (
+	defaultOnce  sync.Once
+	defaultStore *Store
+	defaultErr   error
+)
+
+// Default returns the store of DefaultDir, opened on the first call.
+func Default() (*Store, error) {
+	defaultOnce.Do(func() {
+		dir, err := DefaultDir()
+		if err != nil {
+			defaultErr = err
+			return
+		}
+		defaultStore, defaultErr = Open(dir)
+	})
+	return defaultStore, defaultErr
+}
+
+// Open returns the store of a folder, creating it if needed.
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("error creating cache %s: %w", dir, err)
+	}
+	s := &Store{dir: dir, entries: make(map[string]Entry)}
+	data, err := os.ReadFile(filepath.Join(dir, manifestFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading cache manifest: %w", err)
+	}
+	var entries []Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("error reading cache manifest: %w", err)
+	}
+	for _, e := range entries {
+		s.entries[id(e.Source, e.Key)] = e
+	}
+	return s, nil
+}
+
+// Dir returns the folder of the store.
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// Get returns the data of a key of a source and its entry, ok is false when it is not cached.
+// The error wraps ErrChecksum when the file does not match the manifest.
+func (s *Store) Get(source, key string) (data []byte, e Entry, ok bool, err error) {
+	s.mu.Lock()
+	e, ok = s.entries[id(source, key)]
+	s.mu.Unlock()
+	if !ok {
+		return nil, e, false, nil
+	}
+	data, err = s.read(e)
+	if err != nil {
+		return nil, e, true, err
+	}
+	return data, e, true, nil
+}
+
+func (s *Store) read(e Entry) ([]byte, error) {
+	file := filepath.Join(s.dir, e.file())
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("error opening cache file %s: %w", file, err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("error reading cache file %s: %w", file, err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("error reading cache file %s: %w", file, err)
+	}
+	if provenance.Hash(data) != e.SHA256 {
+		return nil, fmt.Errorf("cache file %s: %w", file, ErrChecksum)
+	}
+	return data, nil
+}
+
+// Put stores the data of a key of a source, replacing what was there.
+func (s *Store) Put(source, key string, data []byte) (Entry, error) {
+	return s.put(source, key, data, false)
+}
+
+// Keep stores the data of a key of a source like Put, but as a record that cannot be fetched
+// again, e.g. a history, which Purge leaves.
+func (s *Store) Keep(source, key string, data []byte) (Entry, error) {
+	return s.put(source, key, data, true)
+}
+
+func (s *Store) put(source, key string, data []byte, kept bool) (Entry, error) {
+	e := Entry{Source: source, Key: key, RawSize: int64(len(data)), SHA256: provenance.Hash(data), StoredAt: time.Now(), Kept: kept}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return e, fmt.Errorf("error compressing %s: %w", id(source, key), err)
+	}
+	if err := zw.Close(); err != nil {
+		return e, fmt.Errorf("error compressing %s: %w", id(source, key), err)
+	}
+	e.Size = int64(buf.Len())
+	if err := writeFile(filepath.Join(s.dir, e.file()), buf.Bytes()); err != nil {
+		return e, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[id(source, key)] = e
+	return e, s.saveManifest()
+}
+
+// saveManifest writes the manifest, s.mu must be held.
+func (s *Store) saveManifest() error {
+	entries := make([]Entry, 0, len(s.entries))
+	for _, e := range s.entries {
+		entries = append(entries, e)
+	}
+	sortEntries(entries)
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error writing cache manifest: %w", err)
+	}
+	return writeFile(filepath.Join(s.dir, manifestFile), data)
+}
+
+// writeFile writes a file through a temporary file renamed over it, so it is never left half written.
+func writeFile(file string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return fmt.Errorf("error creating %s: %w", filepath.Dir(file), err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(file), filepath.Base(file)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("error writing %s: %w", file, err)
+	}
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), file)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("error writing %s: %w", file, err)
+	}
+	return nil
+}
+
+func sortEntries(entries []Entry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Source != entries[j].Source {
+			return entries[i].Source < entries[j].Source
+		}
+		return entries[i].Key < entries[j].Key
+	})
+}
+
+// Filter selects entries. An empty Source selects every source, a zero Before every date.
+type Filter struct {
+	Source string
+	// Before selects the entries stored before it.
+	Before time.Time
+}
+
+func (f Filter) matches(e Entry) bool {
+	return (f.Source == "" || e.Source == f.Source) && (f.Before.IsZero() || e.StoredAt.Before(f.Before))
+}
+
+// Entries returns the entries selected by f, by source and key.
+func (s *Store) Entries(f Filter) []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var entries []Entry
+	for _, e := range s.entries {
+		if f.matches(e) {
+			entries = append(entries, e)
+		}
+	}
+	sortEntries(entries)
+	return entries
+}
+
+// Stats are the totals of the entries of a source.
+type Stats struct {
+	Source  string
+	Count   int
+	Size    int64
+	RawSize int64
+	Oldest  time.Time
+	Newest  time.Time
+}
+
+// Stats returns the totals of each source selected by f, by source.
+func (s *Store) Stats(f Filter) []Stats {
+	var stats []Stats
+	for _, e := range s.Entries(f) {
+		if len(stats) == 0 || stats[len(stats)-1].Source != e.Source {
+			stats = append(stats, Stats{Source: e.Source, Oldest: e.StoredAt, Newest: e.StoredAt})
+		}
+		st := &stats[len(stats)-1]
+		st.Count++
+		st.Size += e.Size
+		st.RawSize += e.RawSize
+		if e.StoredAt.Before(st.Oldest) {
+			st.Oldest = e.StoredAt
+		}
+		if e.StoredAt.After(st.Newest) {
+			st.Newest = e.StoredAt
+		}
+	}
+	return stats
+}
+
+// Problem is an entry or a file of the cache that failed the verification.
+type Problem struct {
+	// File is relative to the folder of the store.
+	File string
+	Err  error
+}
+
+// Verify checks that the entries selected by f can be read and match their checksum,
+// and, when f selects everything, that every file of the cache is in the manifest.
+func (s *Store) Verify(f Filter) ([]Problem, error) {
+	var problems []Problem
+	known := make(map[string]bool)
+	for _, e := range s.Entries(f) {
+		known[e.file()] = true
+		if _, err := s.read(e); err != nil {
+			problems = append(problems, Problem{File: e.file(), Err: err})
+		}
+	}
+	if f != (Filter{}) {
+		return problems, nil
+	}
+	err := filepath.WalkDir(s.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(path, compressedExt) {
+			return nil
+		}
+		rel, err := filepath.Rel(s.dir, path)
+		if err != nil {
+			return err
+		}
+		if !known[rel] {
+			problems = append(problems, Problem{File: rel, Err: ErrNotInManifest})
+		}
+		return nil
+	})
+	if err != nil {
+		return problems, fmt.Errorf("error walking cache %s: %w", s.dir, err)
+	}
+	return problems, nil
+}
+
+// Purge removes the entries selected by f and returns them. The entries stored with Keep are left.
+func (s *Store) Purge(f Filter) ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var removed []Entry
+	for key, e := range s.entries {
+		if e.Kept || !f.matches(e) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.dir, e.file())); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			// the manifest keeps the entries that are still there
+			s.saveManifest()
+			return removed, fmt.Errorf("error removing cache file %s: %w", e.file(), err)
+		}
+		delete(s.entries, key)
+		removed = append(removed, e)
+	}
+	sortEntries(removed)
+	if len(removed) == 0 {
+		return nil, nil
+	}
+	return removed, s.saveManifest()
+}
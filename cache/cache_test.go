@@ -0,0 +1,114 @@
+package cache
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Store(t *testing.T) {
+	a := assert.New(t)
+	dir := t.TempDir()
+	s, err := Open(dir)
+	a.NoError(err)
+
+	_, _, ok, err := s.Get("treasury", "yield/2022-05-01.xml")
+	a.NoError(err)
+	a.False(ok)
+
+	data := []byte("<feed>" + string(make([]byte, 1000)) + "</feed>")
+	e, err := s.Put("treasury", "yield/2022-05-01.xml", data)
+	a.NoError(err)
+	a.Equal(int64(len(data)), e.RawSize)
+	a.Less(e.Size, e.RawSize, "compressed")
+	info, err := os.Stat(filepath.Join(dir, "treasury", "yield", "2022-05-01.xml.gz"))
+	a.NoError(err)
+	a.Equal(os.FileMode(0644), info.Mode().Perm())
+
+	got, _, ok, err := s.Get("treasury", "yield/2022-05-01.xml")
+	a.NoError(err)
+	a.True(ok)
+	a.Equal(data, got)
+
+	// the manifest is read back when the store is opened again
+	s, err = Open(dir)
+	a.NoError(err)
+	got, entry, ok, err := s.Get("treasury", "yield/2022-05-01.xml")
+	a.NoError(err)
+	a.True(ok)
+	a.Equal(data, got)
+	a.Equal(e.SHA256, entry.SHA256)
+	temps, _ := filepath.Glob(filepath.Join(dir, "*.tmp"))
+	a.Empty(temps)
+}
+
+func Test_StoreVerifyPurge(t *testing.T) {
+	a := assert.New(t)
+	dir := t.TempDir()
+	s, err := Open(dir)
+	a.NoError(err)
+	_, err = s.Put("treasury", "a", []byte("a"))
+	a.NoError(err)
+	_, err = s.Put("treasury", "b", []byte("b"))
+	a.NoError(err)
+	_, err = s.Put("other", "c", []byte("c"))
+	a.NoError(err)
+	_, err = s.Keep("treasury", "history", []byte("h"))
+	a.NoError(err)
+
+	stats := s.Stats(Filter{})
+	a.Len(stats, 2)
+	a.Equal("other", stats[0].Source)
+	a.Equal(3, stats[1].Count)
+	a.Equal(int64(3), stats[1].RawSize)
+
+	problems, err := s.Verify(Filter{})
+	a.NoError(err)
+	a.Empty(problems)
+
+	// a file replaced behind the manifest's back and a file it does not know
+	b, _, _, _ := s.Get("treasury", "a")
+	a.Equal([]byte("a"), b)
+	other, err := Open(t.TempDir())
+	a.NoError(err)
+	e, err := other.Put("treasury", "b", []byte("changed"))
+	a.NoError(err)
+	content, err := os.ReadFile(filepath.Join(other.Dir(), e.file()))
+	a.NoError(err)
+	a.NoError(os.WriteFile(filepath.Join(dir, "treasury", "b.gz"), content, 0644))
+	a.NoError(os.WriteFile(filepath.Join(dir, "treasury", "stray.gz"), content, 0644))
+
+	_, _, ok, err := s.Get("treasury", "b")
+	a.True(ok)
+	a.True(errors.Is(err, ErrChecksum))
+	problems, err = s.Verify(Filter{})
+	a.NoError(err)
+	if a.Len(problems, 2) {
+		a.True(errors.Is(problems[0].Err, ErrChecksum))
+		a.Equal(filepath.Join("treasury", "stray.gz"), problems[1].File)
+		a.True(errors.Is(problems[1].Err, ErrNotInManifest))
+	}
+
+	removed, err := s.Purge(Filter{Source: "treasury", Before: time.Now().Add(time.Hour)})
+	a.NoError(err)
+	a.Len(removed, 2)
+	a.Len(s.Entries(Filter{}), 2)
+	removed, err = s.Purge(Filter{})
+	a.NoError(err)
+	a.Len(removed, 1)
+	if entries := s.Entries(Filter{}); a.Len(entries, 1, "the kept entry is left") {
+		a.Equal("history", entries[0].Key)
+	}
+	reopened, err := Open(dir)
+	a.NoError(err)
+	a.Len(reopened.Entries(Filter{}), 1)
+	removed, err = s.Purge(Filter{Before: time.Now().Add(-time.Hour)})
+	a.NoError(err)
+	a.Empty(removed)
+	_, err = os.Stat(filepath.Join(dir, "treasury", "a.gz"))
+	a.True(errors.Is(err, os.ErrNotExist))
+}
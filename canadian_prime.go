@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/clauderoy790/boc-excel-file-maker/prime"
+	"github.com/clauderoy790/boc-excel-file-maker/scrape"
+	"github.com/xuri/excelize/v2"
+)
+
+// getCanadianPrimes scrapes the prime rate of each bank of the Canadian prime scrapers, by bank name. A bank whose
+// page fails is left out so the others still make the consensus, only a cancelled run fails.
+func getCanadianPrimes(ctx context.Context, progress progressFunc) (map[string]float64, error) {
+	rates := make(map[string]float64)
+	banks := scrape.InGroup(scrapers, scrape.GroupCanadianPrime)
+	progress(stepPrimes, 0, len(banks))
+	for i, b := range banks {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		res, err := runScraper(ctx, b)
+		if err == nil {
+			rate := res.Values[scrape.RatePrime]
+			rates[b.Source] = rate
+			slog.Info("scraped prime rate", "source", b.Source, "step", stepPrimes, "rate", rate)
+		}
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, ctxErr
+			}
+			slog.Warn("failed to scrape prime rate", "source", b.Source, "step", stepPrimes, "error", err)
+		}
+		progress(stepPrimes, i+1, len(banks))
+	}
+	return rates, nil
+}
+
+// writeCanadianPrime writes the prime rate of each Canadian bank next to the other prime rates,
+// with the consensus and the banks that diverge from it.
+func writeCanadianPrime(f *excelize.File, date time.Time, rates map[string]float64) error {
+	sheet := wsjSheet
+	consensus, ok := prime.NewConsensus(rates)
+	for _, cell := range [][2]string{
+		{"M1", "Prime canadien"},
+		{"M2", "Taux en date du: " + wsjDate(date)},
+	} {
+		if err := setCell(f, sheet, cell[0], cell[1]); err != nil {
+			return err
+		}
+	}
+	if err := setRow(f, sheet, "M4", []interface{}{"Banque", "Taux", "Consensus", "Écart"}); err != nil {
+		return err
+	}
+	banks := []string{scraper(scrape.BNC).Source}
+	for _, b := range scrape.InGroup(scrapers, scrape.GroupCanadianPrime) {
+		banks = append(banks, b.Source)
+	}
+	for i, bank := range banks {
+		row := []interface{}{bank, "n/a", "n/a", ""}
+		rate, found := rates[bank]
+		if found {
+			row[1] = percent(rate)
+		}
+		if ok {
+			row[2] = percent(consensus.Rate)
+			if found && consensus.Diverges(rate) {
+				row[3] = fmt.Sprintf("Diverge (%+.2f%%)", rate-consensus.Rate)
+			}
+		}
+		if err := setRow(f, sheet, fmt.Sprintf("M%d", 5+i), row); err != nil {
+			return err
+		}
+	}
+	summary := "Aucun taux"
+	if ok {
+		summary = fmt.Sprintf("%d banques sur %d", consensus.Count, consensus.Total)
+		if !consensus.Majority() {
+			summary += ", pas de majorité"
+		}
+	}
+	line := fmt.Sprintf("M%d", 5+len(banks))
+	return setRow(f, sheet, line, []interface{}{"Consensus", "", percentOr(consensus.Rate, ok), summary})
+}
+
+func percentOr(v float64, ok bool) string {
+	if !ok {
+		return "n/a"
+	}
+	return percent(v)
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/clauderoy790/boc-excel-file-maker/quality"
+)
+
+const chartMargin = 40
+
+var chartColors = []color.Color{
+	color.NRGBA{R: 0x1f, G: 0x77, B: 0xb4, A: 0xff},
+	color.NRGBA{R: 0xff, G: 0x7f, B: 0x0e, A: 0xff},
+	color.NRGBA{R: 0x2c, G: 0xa0, B: 0x2c, A: 0xff},
+	color.NRGBA{R: 0xd6, G: 0x27, B: 0x28, A: 0xff},
+	color.NRGBA{R: 0x94, G: 0x67, B: 0xbd, A: 0xff},
+	color.NRGBA{R: 0x8c, G: 0x56, B: 0x4b, A: 0xff},
+}
+
+// lineChart draws series as lines over time, with their values on the vertical axis.
+type lineChart struct {
+	widget.BaseWidget
+	series []*quality.Series
+}
+
+func newLineChart() *lineChart {
+	c := &lineChart{}
+	c.ExtendBaseWidget(c)
+	return c
+}
+
+func (c *lineChart) setSeries(series []*quality.Series) {
+	c.series = series
+	c.Refresh()
+}
+
+func (c *lineChart) CreateRenderer() fyne.WidgetRenderer {
+	return &lineChartRenderer{chart: c}
+}
+
+type lineChartRenderer struct {
+	chart   *lineChart
+	size    fyne.Size
+	objects []fyne.CanvasObject
+}
+
+func (r *lineChartRenderer) Layout(size fyne.Size) {
+	r.size = size
+	r.build()
+}
+
+func (r *lineChartRenderer) MinSize() fyne.Size {
+	return fyne.NewSize(300, 200)
+}
+
+func (r *lineChartRenderer) Refresh() {
+	r.build()
+	canvas.Refresh(r.chart)
+}
+
+func (r *lineChartRenderer) Objects() []fyne.CanvasObject {
+	return r.objects
+}
+
+func (r *lineChartRenderer) Destroy() {}
+
+func (r *lineChartRenderer) build() {
+	r.objects = nil
+	b, ok := newChartBounds(r.chart.series)
+	if !ok {
+		r.objects = append(r.objects, chartText("Select series to show", theme.ForegroundColor(), 0, 0))
+		return
+	}
+	plot := fyne.NewSize(r.size.Width-2*chartMargin, r.size.Height-2*chartMargin)
+	for i, s := range r.chart.series {
+		c := chartColors[i%len(chartColors)]
+		points := chartPoints(s, b, plot)
+		for j := 1; j < len(points); j++ {
+			line := canvas.NewLine(c)
+			line.StrokeWidth = 1.5
+			line.Position1 = points[j-1].Add(fyne.NewPos(chartMargin, chartMargin))
+			line.Position2 = points[j].Add(fyne.NewPos(chartMargin, chartMargin))
+			r.objects = append(r.objects, line)
+		}
+		r.objects = append(r.objects, chartText(s.Sheet+" "+s.Name, c, chartMargin, float32(i)*14))
+	}
+	fg := theme.ForegroundColor()
+	bottom := r.size.Height - chartMargin
+	r.objects = append(r.objects,
+		chartText(fmt.Sprintf("%.2f%%", b.maxValue), fg, 0, chartMargin),
+		chartText(fmt.Sprintf("%.2f%%", b.minValue), fg, 0, bottom-14),
+		chartText(dateString(b.start), fg, chartMargin, bottom+4),
+		chartText(dateString(b.end), fg, r.size.Width-chartMargin-70, bottom+4),
+	)
+}
+
+func chartText(text string, c color.Color, x, y float32) fyne.CanvasObject {
+	t := canvas.NewText(text, c)
+	t.TextSize = 11
+	t.Move(fyne.NewPos(x, y))
+	return t
+}
+
+// chartBounds are the dates and values covered by the series of a chart.
+type chartBounds struct {
+	start, end         time.Time
+	minValue, maxValue float64
+}
+
+func newChartBounds(series []*quality.Series) (chartBounds, bool) {
+	var b chartBounds
+	found := false
+	for _, s := range series {
+		for _, o := range s.Observations {
+			if !found {
+				b = chartBounds{start: o.Date, end: o.Date, minValue: o.Value, maxValue: o.Value}
+				found = true
+				continue
+			}
+			if o.Date.Before(b.start) {
+				b.start = o.Date
+			}
+			if o.Date.After(b.end) {
+				b.end = o.Date
+			}
+			if o.Value < b.minValue {
+				b.minValue = o.Value
+			}
+			if o.Value > b.maxValue {
+				b.maxValue = o.Value
+			}
+		}
+	}
+	return b, found
+}
+
+// chartPoints places the observations of a series in a plot of the given size,
+// keeping at most one point per horizontal pixel.
+func chartPoints(s *quality.Series, b chartBounds, size fyne.Size) []fyne.Position {
+	span := b.end.Sub(b.start).Seconds()
+	valueSpan := b.maxValue - b.minValue
+	var points []fyne.Position
+	for _, o := range s.Observations {
+		x, y := float32(0), size.Height/2
+		if span > 0 {
+			x = float32(o.Date.Sub(b.start).Seconds()/span) * size.Width
+		}
+		if valueSpan > 0 {
+			y = size.Height - float32((o.Value-b.minValue)/valueSpan)*size.Height
+		}
+		p := fyne.NewPos(x, y)
+		if n := len(points); n > 0 && int(points[n-1].X) == int(p.X) {
+			points[n-1] = p
+			continue
+		}
+		points = append(points, p)
+	}
+	return points
+}
@@ -0,0 +1,382 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/clauderoy790/boc-excel-file-maker/cache"
+	"github.com/clauderoy790/boc-excel-file-maker/rates"
+	"github.com/clauderoy790/boc-excel-file-maker/treasury"
+	"github.com/clauderoy790/boc-excel-file-maker/wbdiff"
+	"github.com/xuri/excelize/v2"
+)
+
+// commands can be run instead of the window, as in: boc-excel-file-maker [flags] command args...
+var commands = map[string]struct {
+	usage string
+	run   func(args []string) error
+}{
+	"rate": {
+		usage: "rate <oec|ust> <tenor> [YYYY-MM-DD]: print the rate of a tenor on a day, today by default",
+		run: func(args []string) error {
+			if len(args) < 2 || len(args) > 3 {
+				return fmt.Errorf("usage: rate <oec|ust> <tenor> [YYYY-MM-DD]")
+			}
+			date, err := commandDate(args[2:])
+			if err != nil {
+				return err
+			}
+			ctx, cancel := newRunContext()
+			defer cancel()
+			v, err := rates.RateAt(ctx, rates.Source(args[0]), rates.Tenor(args[1]), date)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%s %s %s: %.4f%%\n", args[0], args[1], dateString(date), v)
+			return nil
+		},
+	},
+	"backfill": {
+		usage: "backfill [-dataset name|all] [from] [to]: load the Treasury data from YYYY or YYYY-MM-DD, the Treasury start by default, to today in the cache a year at a time",
+		run:   runBackfill,
+	},
+	"cache": {
+		usage: "cache <list|stats|verify|purge> [-source name] [-before YYYY-MM-DD]: inspect or clean the cache, purge removing the selected entries but the Treasury histories and revisions",
+		run:   runCache,
+	},
+	"curve": {
+		usage: "curve <oec|ust> [YYYY-MM-DD]: print the rates of every tenor on a day, today by default",
+		run: func(args []string) error {
+			if len(args) < 1 || len(args) > 2 {
+				return fmt.Errorf("usage: curve <oec|ust> [YYYY-MM-DD]")
+			}
+			date, err := commandDate(args[1:])
+			if err != nil {
+				return err
+			}
+			ctx, cancel := newRunContext()
+			defer cancel()
+			source := rates.Source(args[0])
+			curve, err := rates.Curve(ctx, source, date)
+			if err != nil {
+				return err
+			}
+			for _, tenor := range rates.Tenors(source) {
+				if v, ok := curve.Rates[tenor]; ok {
+					fmt.Printf("%s\t%.4f%%\n", tenor, v)
+				}
+			}
+			if src := curve.Provenance; src.URL != "" {
+				fmt.Printf("source\t%s %s, %s %s\n", src.Source, src.Unit, src.Cache, src.FetchedAt.Format(time.RFC3339))
+			}
+			return nil
+		},
+	},
+	"diff": {
+		usage: "diff [-format text|json|sheet] [-o file] <old.xlsx> <new.xlsx>: show the dates added and removed and the values changed between two workbooks",
+		run:   runDiff,
+	},
+	"revisions": {
+		usage: "revisions [dataset] [YYYY-MM-DD]: print the revisions of the Treasury data, or every version of the values of a day",
+		run:   runRevisions,
+	},
+	"replay": {
+		usage: "replay <scraper> <page.html>: run a scraper against a page saved in the diagnostics folder",
+		run: func(args []string) error {
+			if len(args) != 2 {
+				return fmt.Errorf("usage: replay <scraper> <page.html>")
+			}
+			return replayScraper(os.Stdout, args[0], args[1])
+		},
+	},
+}
+
+// commandDate reads the optional date argument of a command, today when there is none.
+func commandDate(args []string) (time.Time, error) {
+	if len(args) == 0 {
+		return time.Now(), nil
+	}
+	date := parsePrefDate(args[0])
+	if date.IsZero() {
+		return date, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", args[0])
+	}
+	return date, nil
+}
+
+// changesSheet is the sheet the diff command writes the changes to.
+const changesSheet = "Changes"
+
+// runDiff compares two workbooks. The report is printed, or written in a Changes sheet of the
+// new workbook, saved to -o or in place.
+func runDiff(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	format := fs.String("format", "text", "the output: text, json or sheet")
+	output := fs.String("o", "", "the file to write to, stdout for text and json and the new workbook for sheet by default")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return fmt.Errorf("usage: diff [-format text|json|sheet] [-o file] <old.xlsx> <new.xlsx>")
+	}
+	oldPath, newPath := fs.Arg(0), fs.Arg(1)
+	report, err := wbdiff.Compare(oldPath, newPath, wbdiff.Options{
+		TableTitle: tableTitle,
+		// the sources and quality sheets change on every run
+		Ignore: []string{sourcesSheet, qualitySheet, changesSheet},
+	})
+	if err != nil {
+		return err
+	}
+
+	switch *format {
+	case "text", "json":
+		w := os.Stdout
+		if *output != "" {
+			f, err := os.Create(*output)
+			if err != nil {
+				return fmt.Errorf("error creating %s: %w", *output, err)
+			}
+			defer f.Close()
+			w = f
+		}
+		if *format == "json" {
+			return report.WriteJSON(w)
+		}
+		return report.WriteText(w)
+	case "sheet":
+		f, err := excelize.OpenFile(newPath)
+		if err != nil {
+			return fmt.Errorf("error opening %s: %w", newPath, err)
+		}
+		defer f.Close()
+		if err := report.WriteSheet(f, changesSheet); err != nil {
+			return err
+		}
+		out := *output
+		if out == "" {
+			out = newPath
+		}
+		if err := f.SaveAs(out); err != nil {
+			return fmt.Errorf("error saving %s: %w", out, err)
+		}
+		fmt.Printf("Changes written to the %s sheet of %s\n", changesSheet, out)
+		return nil
+	default:
+		return fmt.Errorf("unknown format %q, expected text, json or sheet", *format)
+	}
+}
+
+// runCache runs a cache subcommand on the entries selected by the -source and -before flags.
+func runCache(args []string) error {
+	usage := fmt.Errorf("usage: cache <list|stats|verify|purge> [-source name] [-before YYYY-MM-DD]")
+	if len(args) == 0 {
+		return usage
+	}
+	fs := flag.NewFlagSet("cache "+args[0], flag.ContinueOnError)
+	source := fs.String("source", "", "only the entries of a source, e.g. treasury")
+	before := fs.String("before", "", "only the entries stored before a day, YYYY-MM-DD")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return usage
+	}
+	filter := cache.Filter{Source: *source}
+	if *before != "" {
+		filter.Before = parsePrefDate(*before)
+		if filter.Before.IsZero() {
+			return fmt.Errorf("invalid date %q, expected YYYY-MM-DD", *before)
+		}
+	}
+	store, err := cache.Default()
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "list":
+		for _, e := range store.Entries(filter) {
+			fmt.Printf("%s\t%s\t%s\t%s\n", e.Source, e.Key, byteSize(e.Size), e.StoredAt.Format(time.RFC3339))
+		}
+	case "stats":
+		fmt.Println(store.Dir())
+		for _, st := range store.Stats(filter) {
+			fmt.Printf("%s\t%d entries\t%s (%s uncompressed)\t%s to %s\n", st.Source, st.Count, byteSize(st.Size), byteSize(st.RawSize),
+				dateString(st.Oldest), dateString(st.Newest))
+		}
+	case "verify":
+		problems, err := store.Verify(filter)
+		if err != nil {
+			return err
+		}
+		for _, p := range problems {
+			fmt.Printf("%s: %v\n", p.File, p.Err)
+		}
+		if len(problems) > 0 {
+			return fmt.Errorf("%d problems in the cache, purge the entries to fetch them again", len(problems))
+		}
+		fmt.Println("Cache OK")
+	case "purge":
+		removed, err := store.Purge(filter)
+		fmt.Printf("%d entries removed\n", len(removed))
+		return err
+	default:
+		return usage
+	}
+	return nil
+}
+
+// byteSize formats a size for people.
+func byteSize(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
+
+// runBackfill loads years of Treasury data in the cache, so the sheets can start before the months already cached.
+func runBackfill(args []string) error {
+	fs := flag.NewFlagSet("backfill", flag.ContinueOnError)
+	dataset := fs.String("dataset", string(treasury.YieldCurve), "the Treasury dataset, or all")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 2 {
+		return fmt.Errorf("usage: backfill [-dataset name|all] [from] [to]")
+	}
+	datasets := []treasury.Dataset{treasury.Dataset(*dataset)}
+	if *dataset == "all" {
+		datasets = treasury.Datasets
+	} else if !validDataset(datasets[0]) {
+		return fmt.Errorf("unknown dataset %q, expected all or one of %v", *dataset, treasury.Datasets)
+	}
+	from, to := startDateTreasury, time.Now()
+	for i, d := range []*time.Time{&from, &to} {
+		if i >= fs.NArg() {
+			break
+		}
+		date, err := backfillDate(fs.Arg(i), i == 1)
+		if err != nil {
+			return err
+		}
+		*d = date
+	}
+	if to.Before(from) {
+		return fmt.Errorf("%s is before %s", dateString(to), dateString(from))
+	}
+
+	ctx, cancel := newRunContext()
+	defer cancel()
+	for _, ds := range datasets {
+		records, err := treasury.Backfill(ctx, ds, from, to)
+		for _, r := range records {
+			fmt.Printf("%s\t%s\t%s\n", r.Unit, r.Note, r.URL)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backfillDate reads a date of the backfill command, a year being its first day, or its last one for the end.
+func backfillDate(s string, end bool) (time.Time, error) {
+	if year, err := strconv.Atoi(s); err == nil && len(s) == 4 {
+		if end {
+			return time.Date(year, 12, 31, 0, 0, 0, 0, time.Local), nil
+		}
+		return time.Date(year, 1, 1, 0, 0, 0, 0, time.Local), nil
+	}
+	date := parsePrefDate(s)
+	if date.IsZero() {
+		return date, fmt.Errorf("invalid date %q, expected YYYY or YYYY-MM-DD", s)
+	}
+	return date, nil
+}
+
+func validDataset(ds treasury.Dataset) bool {
+	for _, d := range treasury.Datasets {
+		if d == ds {
+			return true
+		}
+	}
+	return false
+}
+
+// runRevisions prints the revision log of a Treasury dataset, of all of them by default,
+// or every version of the values of a day of a dataset.
+func runRevisions(args []string) error {
+	if len(args) > 2 {
+		return fmt.Errorf("usage: revisions [dataset] [YYYY-MM-DD]")
+	}
+	datasets := treasury.Datasets
+	if len(args) > 0 {
+		ds := treasury.Dataset(args[0])
+		if !validDataset(ds) {
+			return fmt.Errorf("unknown dataset %q, expected one of %v", args[0], treasury.Datasets)
+		}
+		datasets = []treasury.Dataset{ds}
+	}
+	if len(args) == 2 {
+		date, err := commandDate(args[1:])
+		if err != nil {
+			return err
+		}
+		fields, err := treasury.History(datasets[0], date)
+		if err != nil {
+			return err
+		}
+		if len(fields) == 0 {
+			fmt.Printf("No history for %s on %s\n", datasets[0], dateString(date))
+			return nil
+		}
+		names := make([]string, 0, len(fields))
+		for name := range fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			for _, v := range fields[name] {
+				fmt.Printf("%s\t%s\t%q\n", name, v.SeenAt.Format(time.RFC3339), v.Value)
+			}
+		}
+		return nil
+	}
+	count := 0
+	for _, ds := range datasets {
+		revisions, err := treasury.Revisions(ds)
+		if err != nil {
+			return err
+		}
+		for _, r := range revisions {
+			fmt.Println(r)
+		}
+		count += len(revisions)
+	}
+	if count == 0 {
+		fmt.Println("No revisions")
+	}
+	return nil
+}
+
+// runCommand runs the command named by the first of args with the others.
+func runCommand(args []string) error {
+	cmd, ok := commands[args[0]]
+	if !ok {
+		var usages []string
+		for _, c := range commands {
+			usages = append(usages, "  "+c.usage)
+		}
+		sort.Strings(usages)
+		return fmt.Errorf("unknown command %q, the commands are:\n%s", args[0], strings.Join(usages, "\n"))
+	}
+	return cmd.run(args[1:])
+}
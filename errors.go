@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// cellError is a failure to read or write a cell of the workbook.
+type cellError struct {
+	sheet string
+	cell  string
+	err   error
+}
+
+func (e *cellError) Error() string {
+	return fmt.Sprintf("sheet %s cell %s: %v", e.sheet, e.cell, e.err)
+}
+
+func (e *cellError) Unwrap() error {
+	return e.err
+}
+
+// valueError is a value from a source that cannot be used.
+type valueError struct {
+	source string
+	date   time.Time
+	field  string
+	value  string
+	err    error
+}
+
+func (e *valueError) Error() string {
+	return fmt.Sprintf("%s %s: invalid %s %q: %v", e.source, dateString(e.date), e.field, e.value, e.err)
+}
+
+func (e *valueError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,87 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Timeout bounds every request, on top of the deadline of the caller's context.
+var Timeout = 30 * time.Second
+
+var client = &http.Client{}
+
+type Response struct {
+	URL       string
+	Status    int
+	Body      []byte
+	FetchedAt time.Time
+}
+
+// SourceError is a failure of the source itself: the request failed, timed out or got a bad status.
+// It is never returned when the caller's context is done, Get returns the context's error instead.
+type SourceError struct {
+	URL    string
+	Status int
+	Err    error
+}
+
+func (e *SourceError) Error() string {
+	if e.Status != 0 {
+		return fmt.Sprintf("%s: status %d: %v", e.URL, e.Status, e.Err)
+	}
+	return fmt.Sprintf("%s: %v", e.URL, e.Err)
+}
+
+func (e *SourceError) Unwrap() error {
+	return e.Err
+}
+
+// IsCancelled tells whether err comes from a cancelled context or an expired deadline of the caller.
+func IsCancelled(err error) bool {
+	var srcErr *SourceError
+	if errors.As(err, &srcErr) {
+		return false
+	}
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// Get fetches url and returns its body. A response with a status other than 200 is a SourceError
+// that still has the response, so callers can record it.
+func Get(ctx context.Context, url string) (*Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("request to %s cancelled: %w", url, err)
+	}
+	reqCtx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, &SourceError{URL: url, Err: fmt.Errorf("error creating request: %w", err)}
+	}
+	resp := &Response{URL: url, FetchedAt: time.Now()}
+	httpResp, err := client.Do(req)
+	if err != nil {
+		return nil, requestError(ctx, url, fmt.Errorf("error making request: %w", err))
+	}
+	defer httpResp.Body.Close()
+	resp.Status = httpResp.StatusCode
+	resp.Body, err = io.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, requestError(ctx, url, fmt.Errorf("error reading body: %w", err))
+	}
+	if resp.Status != http.StatusOK {
+		return resp, &SourceError{URL: url, Status: resp.Status, Err: fmt.Errorf("invalid status code, body: %s", resp.Body)}
+	}
+	return resp, nil
+}
+
+// requestError tells a cancellation by the caller apart from a failure of the source.
+func requestError(ctx context.Context, url string, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return fmt.Errorf("request to %s cancelled: %w", url, ctxErr)
+	}
+	return &SourceError{URL: url, Err: err}
+}
@@ -0,0 +1,70 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Get(t *testing.T) {
+	a := assert.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		w.Write([]byte("rates"))
+	}))
+	defer srv.Close()
+
+	resp, err := Get(context.Background(), srv.URL)
+	a.NoError(err)
+	a.Equal(http.StatusOK, resp.Status)
+	a.Equal("rates", string(resp.Body))
+	a.False(resp.FetchedAt.IsZero())
+
+	resp, err = Get(context.Background(), srv.URL+"/missing")
+	var srcErr *SourceError
+	a.True(errors.As(err, &srcErr))
+	a.Equal(http.StatusNotFound, srcErr.Status)
+	a.NotNil(resp)
+	a.False(IsCancelled(err))
+}
+
+func Test_GetCancelled(t *testing.T) {
+	a := assert.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := Get(ctx, srv.URL)
+	a.Error(err)
+	a.True(IsCancelled(err))
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = Get(cancelled, srv.URL)
+	a.True(errors.Is(err, context.Canceled))
+}
+
+func Test_GetTimeout(t *testing.T) {
+	a := assert.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	defer func(d time.Duration) { Timeout = d }(Timeout)
+	Timeout = 50 * time.Millisecond
+	_, err := Get(context.Background(), srv.URL)
+	var srcErr *SourceError
+	a.True(errors.As(err, &srcErr))
+	a.False(IsCancelled(err))
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"github.com/clauderoy790/boc-excel-file-maker/quality"
+	"github.com/clauderoy790/boc-excel-file-maker/valet"
+	"github.com/xuri/excelize/v2"
+)
+
+const fxHeader = "Taux de change quotidiens de la Banque du Canada\nSource: feuille Sources\nhttps://www.banqueducanada.ca/taux/taux-de-change/taux-de-change-quotidiens/\n"
+
+// fxSeries are the Valet series of fxColumns, in the same order
+var fxSeries = []string{"FXUSDCAD", "FXEURCAD", "FXGBPCAD", "FXJPYCAD", "FXCHFCAD", "FXAUDCAD", "FXCNYCAD", "FXMXNCAD"}
+
+var fxColumns = []string{"USD/CAD", "EUR/CAD", "GBP/CAD", "JPY/CAD", "CHF/CAD", "AUD/CAD", "CNY/CAD", "MXN/CAD"}
+
+// fxQuality checks the exchange rates, which are in Canadian dollars rather than in percent.
+var fxQuality = &quality.Config{MaxDailyChange: 0.05, MinValue: 0, MaxValue: 10, StaleAfter: quality.DefaultConfig().StaleAfter}
+
+// writeFXSheet writes the daily exchange rates of the chosen currencies on the dates of the OEC sheet,
+// "n/a" on the days without a rate like the rate sheets.
+func writeFXSheet(ctx context.Context, f *excelize.File, opts options, progress progressFunc) ([]*quality.Series, error) {
+	sheet := fxSheet
+	columns := orderedSelection(fxColumns, opts.columns[sheet])
+	if len(columns) == 0 {
+		return nil, &stepError{step: stepFX, err: fmt.Errorf("no currency selected")}
+	}
+	var ids []string
+	for i, c := range fxColumns {
+		if contains(columns, c) {
+			ids = append(ids, fxSeries[i])
+		}
+	}
+	start := opts.startDate(oecStartDate())
+	end := opts.endDate()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	progress(stepFX, 0, 1)
+	obs, err := valet.FetchSeries(ctx, ids, start, end)
+	if err != nil {
+		return nil, &stepError{step: stepFX, err: err}
+	}
+	sources.Add(obs.Source)
+	slog.Info("fetched exchange rates", "source", valet.SourceName, "step", stepFX, "series", ids)
+
+	f.NewSheet(sheet)
+	if err := writeHeader(f, sheet, fxHeader); err != nil {
+		return nil, err
+	}
+	titles := []interface{}{tableTitle}
+	for _, c := range columns {
+		titles = append(titles, c)
+	}
+	if err := setRow(f, sheet, "A5", titles); err != nil {
+		return nil, err
+	}
+	series := newSheetSeries(sheet, columns, true, false)
+	for _, s := range series {
+		s.Config = fxQuality
+	}
+	line := 6
+	for date := start; !date.After(end); date = date.AddDate(0, 0, 1) {
+		row := []interface{}{colDateString(date)}
+		for i, id := range ids {
+			v, ok := obs.Value(id, date)
+			if !ok {
+				row = append(row, "n/a")
+				continue
+			}
+			row = append(row, strconv.FormatFloat(v, 'f', -1, 64))
+			series[i].Add(date, v)
+		}
+		if err := setRow(f, sheet, fmt.Sprintf("A%d", line), row); err != nil {
+			return nil, err
+		}
+		line++
+	}
+	progress(stepFX, 1, 1)
+	return series, nil
+}
@@ -1,19 +1,18 @@
 module github.com/clauderoy790/boc-excel-file-maker
 
-go 1.18
+go 1.21
 
 require (
 	fyne.io/fyne/v2 v2.1.4
 	github.com/PuerkitoBio/goquery v1.8.0
-	github.com/basgys/goxml2json v1.1.0
 	github.com/clauderoy790/bank-of-canada-interests-rates v0.0.1
 	github.com/stretchr/testify v1.7.1
 	github.com/xuri/excelize/v2 v2.6.0
+	golang.org/x/net v0.0.0-20220407224826-aac1ed45d8e3
 )
 
 require (
 	github.com/andybalholm/cascadia v1.3.1 // indirect
-	github.com/bitly/go-simplejson v0.5.0 // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/fredbi/uri v0.0.0-20181227131451-3dcfdacbaaf3 // indirect
 	github.com/fsnotify/fsnotify v1.4.9 // indirect
@@ -32,7 +31,6 @@ require (
 	github.com/yuin/goldmark v1.3.8 // indirect
 	golang.org/x/crypto v0.0.0-20220408190544-5352b0902921 // indirect
 	golang.org/x/image v0.0.0-20211028202545-6944b10bf410 // indirect
-	golang.org/x/net v0.0.0-20220407224826-aac1ed45d8e3 // indirect
 	golang.org/x/sys v0.0.0-20211216021012-1d35b9e2eb4e // indirect
 	golang.org/x/text v0.3.7 // indirect
 	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c // indirect
@@ -1,13 +1,16 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"io"
-	"net/http"
+	"log/slog"
+	"os"
 	"strconv"
 	"strings"
 	"time"
-	"unicode"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
@@ -16,7 +19,12 @@ import (
 	"fyne.io/fyne/v2/widget"
 	"github.com/PuerkitoBio/goquery"
 	boc "github.com/clauderoy790/bank-of-canada-interests-rates"
-	"github.com/clauderoy790/boc-excel-file-maker/common"
+	"github.com/clauderoy790/boc-excel-file-maker/cache"
+	"github.com/clauderoy790/boc-excel-file-maker/fetch"
+	"github.com/clauderoy790/boc-excel-file-maker/provenance"
+	"github.com/clauderoy790/boc-excel-file-maker/quality"
+	"github.com/clauderoy790/boc-excel-file-maker/rates"
+	"github.com/clauderoy790/boc-excel-file-maker/scrape"
 	"github.com/clauderoy790/boc-excel-file-maker/treasury"
 	"github.com/xuri/excelize/v2"
 )
@@ -25,46 +33,140 @@ const wsjSheet = "Wall St Prime"
 
 const oecHeader = "Historique taux des obligations\nhttp://www.banqueducanada.ca/taux/taux-dinteret/obligations-canadiennes/\n** À partir du 20/04/2021,Taux 1 an = taux 2 ans\n"
 
-var treasHeader = "Historique taux des obligations\n\nhttps://home.treasury.gov/resource-center/data-chart-center/interest-rates/TextView?type=daily_treasury_yield_curve&field_tdr_date_value_month=" + fmt.Sprintf("%04d%02d", time.Now().Year(), int(time.Now().Month())) + "\n"
+const treasHeader = "Historique taux des obligations\nSource de chaque mois: feuille Sources\nhttps://home.treasury.gov/resource-center/data-chart-center/interest-rates/TextView?type=daily_treasury_yield_curve\n"
 
 const startDateOEC = "2014-10-24"
 
+// oecStartDate is the first date of the OEC sheet and of the sheets aligned with it.
+func oecStartDate() time.Time {
+	start := parseToDate(startDateOEC)
+	return time.Date(start.year, time.Month(start.month), start.day, 0, 0, 0, 0, time.Local)
+}
+
+// startDateTreasury is the first day of the Treasury sheets, set by the -treasury-start flag.
 var startDateTreasury = time.Date(2015, 6, 19, 0, 0, 0, 0, time.Local)
 
 const filePath = "./rates.xlsx"
 
+const (
+	bocSource = rates.BoCSourceName
+)
+
+// rateClient looks up the OEC yields and the Treasury curve of the current run, a run uses a new one to get the latest rates.
+var rateClient = rates.NewClient()
+
 var bank boc.BOCInterests
 
+// sources records where the data of the current run came from.
+var sources = provenance.NewLog()
+
+// strictQuality makes the run fail instead of saving when the quality checks find errors.
+var strictQuality bool
+
+// runTimeout bounds a whole run, 0 means no deadline.
+var runTimeout time.Duration
+
 func main() {
+	os.Exit(run())
+}
+
+// run runs the command or the app and returns the exit code, once its deferred calls,
+// closing the log file, have run.
+func run() int {
+	flag.BoolVar(&strictQuality, "strict", false, "fail without saving the file when the data quality checks find errors")
+	flag.DurationVar(&runTimeout, "timeout", 0, "maximum duration of a run, 0 for no limit")
+	flag.DurationVar(&fetch.Timeout, "request-timeout", fetch.Timeout, "maximum duration of each request")
+	treasuryStart := flag.String("treasury-start", "", "first day of the Treasury sheets, YYYY-MM-DD, "+dateString(startDateTreasury)+" when empty")
+	flag.StringVar(&cache.Dir, "cache-dir", "", "folder of the cache, boc-excel-file-maker in the user cache directory when empty")
+	flag.DurationVar(&treasury.MaxAge, "treasury-max-age", 0, "age after which any cached Treasury month is fetched again to find revisions, 0 to fetch only the last "+strconv.Itoa(treasury.RecentMonths)+" months again after "+treasury.RecentMaxAge.String())
+	logFile := flag.String("log-file", "", "file to write the logs to, stderr when empty")
+	logLevel := flag.String("log-level", "info", "minimum level of the logs: debug, info, warn or error")
+	valetConfigFile := flag.String("valet-config", "", "JSON file of Bank of Canada Valet series to add as columns or sheets")
+	scrapersFile := flag.String("scrapers", "", "JSON file of scraper definitions replacing or adding to the built-in ones")
+	flag.StringVar(&diagnosticsDir, "diagnostics", "", "folder of the pages and reports of failed scrapers, diagnostics next to the executable when empty")
+	flag.Parse()
+	closeLog, err := setupLogging(*logFile, *logLevel)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer closeLog()
+	if *valetConfigFile != "" {
+		cfg, err := loadValetConfig(*valetConfigFile)
+		if err != nil {
+			slog.Error("invalid valet configuration", "file", *valetConfigFile, "error", err)
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		addValetSheets(cfg)
+	}
+	if *treasuryStart != "" {
+		start := parsePrefDate(*treasuryStart)
+		if start.IsZero() {
+			fmt.Fprintf(os.Stderr, "invalid -treasury-start %q, expected YYYY-MM-DD\n", *treasuryStart)
+			return 1
+		}
+		startDateTreasury = start
+	}
+	if *scrapersFile != "" {
+		if err := loadScrapers(*scrapersFile); err != nil {
+			slog.Error("invalid scrapers", "file", *scrapersFile, "error", err)
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	if flag.NArg() > 0 {
+		if err := runCommand(flag.Args()); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	}
 	startApp()
+	return 0
 }
 
 func startApp() {
-	a := app.New()
+	a := app.NewWithID("com.clauderoy790.boc-excel-file-maker")
 	w := a.NewWindow("Bank Rates Excel")
 	w.Resize(fyne.Size{
 		Width:  640,
 		Height: 480,
 	})
-	infinite := widget.NewProgressBarInfinite()
-	progress := container.NewVBox(infinite)
-	infinite.Show()
 
+	form, formContent := newOptionsForm(w, loadOptions(a.Preferences()))
 	var btn *fyne.Container
 	btn = container.NewVBox(
+		formContent,
 		widget.NewButton("Generate Excel", func() {
-			w.SetContent(progress)
-			err := writeExcelFile()
-			infinite.Hide()
-			w.SetContent(btn)
+			opts, err := form.options()
 			if err != nil {
-				e := dialog.NewError(fmt.Errorf("there was an error!: %w", err), w)
-				fmt.Println(err)
-				e.Show()
-			} else {
-				confirm := dialog.NewInformation("Success!", "Your file was generated successfully!", w)
-				confirm.Show()
+				dialog.ShowError(err, w)
+				return
 			}
+			saveOptions(a.Preferences(), opts)
+			slog.Info("run started", "sheets", opts.sheets, "output", opts.output)
+			ctx, cancel := newRunContext()
+			progress := newProgressView(runSteps(opts), cancel)
+			w.SetContent(progress.content)
+			go func() {
+				defer cancel()
+				wb, err := buildWorkbook(ctx, opts, progress.update)
+				if err != nil {
+					w.SetContent(btn)
+					showResult(w, opts, err)
+					return
+				}
+				preview := newPreview(w, wb, func() {
+					err := wb.save(opts)
+					w.SetContent(btn)
+					showResult(w, opts, err)
+				}, func() {
+					wb.file.Close()
+					w.SetContent(btn)
+				})
+				w.SetContent(preview)
+			}()
 		}),
 	)
 
@@ -73,43 +175,205 @@ func startApp() {
 	w.ShowAndRun()
 }
 
-func writeExcelFile() error {
-	var err error
-	bank, err = boc.NewBOCInterests()
-	if err != nil {
-		return fmt.Errorf("error creating boc: %w", err)
+// newRunContext returns the context of a run, bounded by the -timeout flag.
+func newRunContext() (context.Context, context.CancelFunc) {
+	if runTimeout > 0 {
+		return context.WithTimeout(context.Background(), runTimeout)
 	}
+	return context.WithCancel(context.Background())
+}
 
-	f := excelize.NewFile()
-	writeOECSheet(f)
-	if err := writeUSTresory(f); err != nil {
-		return fmt.Errorf("error writing traesury: %w", err)
-	}
-	if err := WriteWallStPrime(f); err != nil {
-		return fmt.Errorf("error writing WSJ: %w", err)
+func showResult(w fyne.Window, opts options, err error) {
+	if fetch.IsCancelled(err) {
+		slog.Warn("run cancelled", "err", err)
+		msg := "The file was not generated."
+		if errors.Is(err, context.DeadlineExceeded) {
+			msg = fmt.Sprintf("The run took more than %s, the file was not generated.", runTimeout)
+		}
+		dialog.ShowInformation("Cancelled", msg, w)
+		return
 	}
-	f.SetActiveSheet(0)
-	// Save spreadsheet
-	if err := f.SaveAs(filePath); err != nil {
-		return fmt.Errorf("failed to write file: %w", err)
+	if err != nil {
+		msg := err.Error()
+		var stepErr *stepError
+		if errors.As(err, &stepErr) {
+			msg = fmt.Sprintf("Failed step: %s\n\n%s", stepErr.step, err)
+			slog.Error("run failed", "step", stepErr.step, "err", err)
+		} else {
+			slog.Error("run failed", "err", err)
+		}
+		dialog.ShowError(fmt.Errorf("there was an error!: %s", msg), w)
+		return
 	}
-	f.Close()
+	slog.Info("file saved", "path", opts.output)
+	dialog.ShowInformation("Success!", fmt.Sprintf("Your file was generated successfully!\n%s", opts.output), w)
+}
 
-	time.Sleep(time.Second * 2)
+// workbook is a generated workbook that has not been saved yet.
+type workbook struct {
+	file *excelize.File
+	// series are the values of the daily and prime sheets, used for the checks and the preview.
+	series []*quality.Series
+}
 
-	if err := updateWSJ(); err != nil {
-		return fmt.Errorf("error updating wsj: %w", err)
+// save writes the workbook to the output file, or fills the template with it when there is one.
+// With the update option, the sheets of an existing output file are replaced and the others kept.
+func (wb *workbook) save(opts options) error {
+	defer wb.file.Close()
+	if opts.template != "" {
+		return wb.saveTemplate(opts.template, opts.output)
+	}
+	if opts.update && exists(opts.output) {
+		return wb.saveInto(opts.output)
+	}
+	wb.file.SetActiveSheet(0)
+	if err := wb.file.SaveAs(opts.output); err != nil {
+		return &stepError{step: stepWriting, err: fmt.Errorf("failed to write file: %w", err)}
 	}
 	return nil
 }
 
-func updateWSJ() error {
+// writeExcelFile fetches the data of the selected sheets and writes the workbook.
+func writeExcelFile(ctx context.Context, opts options, progress progressFunc) error {
+	wb, err := buildWorkbook(ctx, opts, progress)
+	if err != nil {
+		return err
+	}
+	return wb.save(opts)
+}
+
+// buildWorkbook fetches the data of the selected sheets and builds the workbook in memory.
+// It stops between fetches when ctx is cancelled.
+func buildWorkbook(ctx context.Context, opts options, progress progressFunc) (*workbook, error) {
 	var err error
-	f, err := excelize.OpenFile(filePath)
+	sources = provenance.NewLog()
+	f := excelize.NewFile()
+	var series []*quality.Series
+	rateClient = rates.NewClient()
+	fetched, err := fetchValet(ctx, opts, progress)
 	if err != nil {
-		return fmt.Errorf("error opening file: %w", err)
+		return nil, err
+	}
+	if opts.includes(oecSheet) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		progress(stepBoC, 0, 1)
+		bank, err = rateClient.BankOfCanada(ctx)
+		if err != nil {
+			return nil, &stepError{step: stepBoC, err: fmt.Errorf("error creating boc: %w", err)}
+		}
+		src, err := rateClient.Provenance(ctx, rates.OEC, opts.endDate())
+		if err != nil {
+			return nil, &stepError{step: stepBoC, err: err}
+		}
+		sources.Add(src)
+		slog.Info("fetched bond yields", "source", bocSource, "step", stepBoC)
+		oecSeries, err := writeOECSheet(ctx, f, opts, fetched[oecSheet])
+		if err != nil {
+			return nil, &stepError{step: stepBoC, err: fmt.Errorf("error writing OEC: %w", err)}
+		}
+		series = append(series, oecSeries...)
+		progress(stepBoC, 1, 1)
+	}
+	if opts.includes(fxSheet) {
+		fxSeries, err := writeFXSheet(ctx, f, opts, progress)
+		if err != nil {
+			return nil, fmt.Errorf("error writing %s: %w", fxSheet, err)
+		}
+		series = append(series, fxSeries...)
+	}
+	for _, ds := range treasDatasets {
+		if !opts.includes(ds.sheet) {
+			continue
+		}
+		treasSeries, err := writeTreasurySheet(ctx, f, opts, progress, ds)
+		if err != nil {
+			return nil, fmt.Errorf("error writing %s: %w", ds.sheet, err)
+		}
+		series = append(series, treasSeries...)
 	}
-	_, err = getwsjData(f, "9")
+	for _, s := range activeValetSheets(opts) {
+		if s.Name == oecSheet {
+			continue
+		}
+		valetSeries, err := writeValetSheet(f, opts, fetched[s.Name])
+		if err != nil {
+			return nil, &stepError{step: stepValet, err: fmt.Errorf("error writing %s: %w", s.Name, err)}
+		}
+		series = append(series, valetSeries...)
+	}
+	var wsj wsjPrime
+	if opts.includes(wsjSheet) || opts.includes(policySheet) {
+		if wsj, err = fetchWSJ(ctx, progress); err != nil {
+			return nil, err
+		}
+	}
+	if opts.includes(wsjSheet) {
+		primeSeries, err := WriteWallStPrime(ctx, f, progress, wsj.rate)
+		if err != nil {
+			return nil, fmt.Errorf("error writing WSJ: %w", err)
+		}
+		series = append(series, primeSeries...)
+	}
+	if opts.includes(policySheet) {
+		policySeries, err := writePolicySheet(ctx, f, opts, progress, wsj)
+		if err != nil {
+			return nil, fmt.Errorf("error writing %s: %w", policySheet, err)
+		}
+		series = append(series, policySeries...)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := writeAggregateSheets(f, opts, series); err != nil {
+		return nil, &stepError{step: stepWriting, err: err}
+	}
+
+	progress(stepQuality, 0, 1)
+	findings := quality.Check(qualityConfig(opts), series)
+	errs := quality.Errors(findings)
+	slog.Info("checked data quality", "step", stepQuality, "errors", len(errs), "warnings", len(findings)-len(errs))
+	if opts.includes(qualitySheet) {
+		if err := writeQualitySheet(f, findings); err != nil {
+			return nil, &stepError{step: stepQuality, err: fmt.Errorf("error writing quality: %w", err)}
+		}
+	}
+	if strictQuality && len(errs) > 0 {
+		return nil, &stepError{step: stepQuality, err: fmt.Errorf("%d data quality errors, first: %s %s %s: %s", len(errs), errs[0].Sheet, errs[0].Series, dateString(errs[0].Date), errs[0].Message)}
+	}
+	progress(stepQuality, 1, 1)
+
+	progress(stepWriting, 0, 1)
+	if opts.includes(sourcesSheet) {
+		if err := writeSourcesSheet(f, sources.Records()); err != nil {
+			return nil, &stepError{step: stepWriting, err: fmt.Errorf("error writing sources: %w", err)}
+		}
+	}
+	// the default sheet is renamed by the OEC sheet, remove it when OEC is not included
+	if !opts.includes(oecSheet) {
+		f.DeleteSheet("Sheet1")
+	}
+	if opts.includes(wsjSheet) {
+		if err := updateWSJ(f); err != nil {
+			return nil, &stepError{step: stepWriting, err: fmt.Errorf("error updating wsj: %w", err)}
+		}
+	}
+	for _, sheet := range f.GetSheetList() {
+		if err := typeDailyCells(f, sheet); err != nil {
+			return nil, &stepError{step: stepWriting, detail: sheet, err: err}
+		}
+	}
+	if err := addDefinedNames(f); err != nil {
+		return nil, &stepError{step: stepWriting, err: fmt.Errorf("error adding defined names: %w", err)}
+	}
+	progress(stepWriting, 1, 1)
+	return &workbook{file: f, series: series}, nil
+}
+
+// updateWSJ moves the rates fetched today up the Wall St Prime sheet when they changed.
+func updateWSJ(f *excelize.File) error {
+	_, err := getwsjData(f, "9")
 	if err != nil {
 		return fmt.Errorf("error getting wsj data: %w", err)
 	}
@@ -136,12 +400,11 @@ func updateWSJ() error {
 			return fmt.Errorf("error shifting data: %w", err)
 		}
 	}
-	err = f.RemoveRow(wsjSheet, 11)
-	if err != nil {
-		return err
-	}
-	if err := f.SaveAs(filePath); err != nil {
-		panic(fmt.Errorf("failed to write file: %w", err))
+	// clear the rates fetched today, the Canadian prime rates next to them stay
+	for _, col := range []string{"A", "B", "G", "H", "J", "K"} {
+		if err := setCell(f, wsjSheet, col+"11", nil); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -155,13 +418,10 @@ func shiftData(f *excelize.File, col string) error {
 			previous := currCol + fmt.Sprintf("%d", row-1)
 			v, err := f.GetCellValue(wsjSheet, curr)
 			if err != nil {
-				return err
+				return &cellError{sheet: wsjSheet, cell: curr, err: err}
 			}
 
-			err = f.SetCellValue(wsjSheet, previous, nil)
-			time.Sleep(time.Second / 100)
-			err = f.SetCellValue(wsjSheet, previous, v)
-			if err != nil {
+			if err := setCell(f, wsjSheet, previous, v); err != nil {
 				return err
 			}
 		}
@@ -204,220 +464,341 @@ type rate struct {
 func newRate(f *excelize.File, line, col string) (*rate, error) {
 	dt, err := f.GetCellValue(wsjSheet, col+line)
 	if err != nil {
-		return nil, err
+		return nil, &cellError{sheet: wsjSheet, cell: col + line, err: err}
 	}
 	next := string(rune(int([]rune(col)[0]) + 1))
 	perc, err := f.GetCellValue(wsjSheet, next+line)
 	if err != nil {
-		return nil, err
+		return nil, &cellError{sheet: wsjSheet, cell: next + line, err: err}
+	}
+	date, err := toDate(dt)
+	if err != nil {
+		return nil, &cellError{sheet: wsjSheet, cell: col + line, err: err}
 	}
 
 	return &rate{
-		date: toDate(dt),
+		date: date,
 		val:  perc,
 	}, nil
 }
 
-func toDate(dt string) time.Time {
+// toDate parses a date written like 4-May-22.
+func toDate(dt string) (time.Time, error) {
 	parts := strings.Split(dt, "-")
-	d, _ := strconv.Atoi(parts[0])
+	if len(parts) != 3 || len(parts[1]) < 3 {
+		return time.Time{}, fmt.Errorf("invalid date %q, expected a date like 4-May-22", dt)
+	}
+	d, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid day in date %q: %w", dt, err)
+	}
 
 	month := time.January
-	for {
-		if strings.HasPrefix(month.String(), parts[1]) {
-			break
+	for !strings.HasPrefix(month.String(), parts[1]) {
+		if month == time.December {
+			return time.Time{}, fmt.Errorf("invalid month in date %q", dt)
 		}
 		month = time.Month(int(month) + 1)
 	}
 
-	y, _ := strconv.Atoi("20" + parts[2])
-	return time.Date(y, month, d, 0, 0, 0, 0, time.Local)
+	y, err := strconv.Atoi("20" + parts[2])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid year in date %q: %w", dt, err)
+	}
+	return time.Date(y, month, d, 0, 0, 0, 0, time.Local), nil
 }
 
-func writeUSTresory(f *excelize.File) error {
-	sheet := "US Tresory"
-	f.NewSheet(sheet)
-	f.SetActiveSheet(1)
-	header := getHeader(treasHeader)
-	for i, str := range header {
-		err := f.SetCellValue(sheet, fmt.Sprintf("A%d", (i+1)), str)
-		if err != nil {
-			panic(fmt.Errorf("error setting sheet vlaue: %w", err))
-		}
+// monthsBetween returns the number of calendar months from start to end, both included.
+func monthsBetween(start, end time.Time) int {
+	if end.Before(start) {
+		return 0
 	}
+	return (end.Year()-start.Year())*12 + int(end.Month()) - int(start.Month()) + 1
+}
 
-	currDate := startDateTreasury
-	now := time.Now()
-	tomorrow := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
-	tomorrow.Add(time.Hour * 25)
-	line := 6
-	prevMonth, curMonth := -1, 0
-	var data *treasury.Treasury
-	for {
-		if prevMonth != curMonth {
-			var err error
-			data, err = treasury.FetchData(currDate)
-			if err != nil {
-				return fmt.Errorf("error fetching treasury data for date %s: %w", dateString(currDate), err)
-			}
-
-		}
+func dateString(dt time.Time) string {
+	return fmt.Sprintf("%04d-%02d-%02d", dt.Year(), int(dt.Month()), dt.Day())
+}
 
-		rowData := getTreasRowData(currDate, data)
-		if err := f.SetSheetRow(sheet, fmt.Sprintf("A%v", line), &rowData); err != nil {
-			panic(err)
-		}
-		currDate = currDate.Add(24 * time.Hour)
-		prevMonth = curMonth
-		curMonth = int(currDate.Month())
-		line++
-		if currDate.After(now) {
-			break
-		}
-	}
-	return nil
+// wsjHistory are the changes of the WSJ prime rate before the rate fetched at each run.
+var wsjHistory = []struct {
+	date string
+	rate float64
+}{
+	{"19-Sep-19", 5.00},
+	{"31-Oct-19", 4.75},
+	{"4-Mar-20", 4.25},
+	{"16-Mar-20", 3.25},
+	{"17-Mar-22", 3.50},
+	{"4-May-22", 4.00},
 }
 
-func getTreasRowData(date time.Time, treas *treasury.Treasury) []interface{} {
-	props, err := treas.GetPropsForDate(dateString(date))
+// fetchWSJ scrapes the current WSJ prime rate and its history, used by the prime and the US policy sheets.
+func fetchWSJ(ctx context.Context, progress progressFunc) (wsjPrime, error) {
+	if err := ctx.Err(); err != nil {
+		return wsjPrime{}, err
+	}
+	progress(stepWSJ, 0, 1)
+	p, err := getWSJ(ctx)
 	if err != nil {
-		return []interface{}{colDateString(date), "n/a", "n/a", "n/a", "n/a", "n/a", "n/a", "n/a", "n/a", "n/a"}
+		return wsjPrime{}, &stepError{step: stepWSJ, err: fmt.Errorf("error getting WSJ data: %w", err)}
 	}
-	return []interface{}{colDateString(date), formatFloat(props.Bc1Year.Content), formatFloat(props.Bc2Year.Content), formatFloat(props.Bc3Year.Content), formatFloat(props.Bc4Year.Content), formatFloat(props.Bc5Year.Content), formatFloat(props.Bc6Year.Content), formatFloat(props.Bc7Year.Content), formatFloat(props.Bc8Year.Content), formatFloat(props.Bc10Year.Content)}
-}
-
-func dateString(dt time.Time) string {
-	return fmt.Sprintf("%04d-%02d-%02d", dt.Year(), int(dt.Month()), dt.Day())
+	slog.Info("scraped prime rate", "source", scraper(scrape.WSJ).Source, "rate", p.rate, "changes", len(p.history))
+	progress(stepWSJ, 1, 1)
+	return p, nil
 }
 
-func WriteWallStPrime(f *excelize.File) error {
+// WriteWallStPrime writes the history of the prime rates and today's rates, val being the WSJ prime.
+func WriteWallStPrime(ctx context.Context, f *excelize.File, progress progressFunc, val float64) ([]*quality.Series, error) {
 	sheet := wsjSheet
 	f.NewSheet(sheet)
 	f.SetActiveSheet(2)
 
 	//firt line
-	_ = f.SetCellValue(sheet, "A1", "Wall Street #45")
-	_ = f.SetCellValue(sheet, "B1", "https://www.wsj.com/market-data/bonds")
-	_ = f.SetCellValue(sheet, "G1", "Prime US BNC(#3)")
-	_ = f.SetCellValue(sheet, "J1", "Prime CAN BNC (#2)")
-	_ = f.SetCellValue(sheet, "G2", "https://www.bnc.ca/fr/taux-et-analyses/taux-dinteret-et-rendements/taux-de-base.html")
+	titles := [][2]string{
+		{"A1", "Wall Street #45"},
+		{"B1", "https://www.wsj.com/market-data/bonds"},
+		{"G1", "Prime US BNC(#3)"},
+		{"J1", "Prime CAN BNC (#2)"},
+		{"G2", scraper(scrape.BNC).URL},
+	}
+	for _, t := range titles {
+		if err := setCell(f, sheet, t[0], t[1]); err != nil {
+			return nil, err
+		}
+	}
 
 	//existing
-	writeFirst2Cells(f, sheet, "5", "19-Sep-19", "5.00%")
-	writeFirst2Cells(f, sheet, "6", "31-Oct-19", "4.75%")
-	writeFirst2Cells(f, sheet, "7", "4-Mar-20", "4.25%")
-	writeFirst2Cells(f, sheet, "8", "16-Mar-20", "3.25%")
-	writeFirst2Cells(f, sheet, "9", "17-Mar-22", "3.50%")
-	writeFirst2Cells(f, sheet, "10", "4-May-22", "4.00%")
-
-	writeBNCells(f, sheet, "5", "20-Sep-19", float64(5.5), "25-Oct-18", float64(3.95))
-	writeBNCells(f, sheet, "6", "1-Nov-19", float64(5.25), "6-Mar-20", float64(3.45))
-	writeBNCells(f, sheet, "7", "6-Mar-20", float64(4.75), "17-Mar-20", float64(2.95))
-	writeBNCells(f, sheet, "8", "17-Mar-20", float64(3.75), "31-Mar-20", float64(2.45))
-	writeBNCells(f, sheet, "9", "17-Mar-22", float64(4.00), "3-Mar-22", float64(2.70))
-	writeBNCells(f, sheet, "10", "5-May-22", float64(4.50), "14-Mar-22", float64(3.20))
-
-	us, can, err := getBNData()
+	for i, change := range wsjHistory {
+		if err := writeFirst2Cells(f, sheet, strconv.Itoa(5+i), change.date, percent(change.rate)); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, cells := range []struct {
+		line, v1 string
+		us       float64
+		v3       string
+		can      float64
+	}{
+		{"5", "20-Sep-19", 5.5, "25-Oct-18", 3.95},
+		{"6", "1-Nov-19", 5.25, "6-Mar-20", 3.45},
+		{"7", "6-Mar-20", 4.75, "17-Mar-20", 2.95},
+		{"8", "17-Mar-20", 3.75, "31-Mar-20", 2.45},
+		{"9", "17-Mar-22", 4.00, "3-Mar-22", 2.70},
+		{"10", "5-May-22", 4.50, "14-Mar-22", 3.20},
+	} {
+		if err := writeBNCells(f, sheet, cells.line, cells.v1, cells.us, cells.v3, cells.can); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	progress(stepBNC, 0, 1)
+	bncRates, err := getBNRates(ctx)
 	if err != nil {
-		return fmt.Errorf("error getting BN data: %w", err)
+		return nil, &stepError{step: stepBNC, err: fmt.Errorf("error getting BN data: %w", err)}
+	}
+	usPrime, _ := scrape.Named(bncRates, scrape.RateUS)
+	canPrime, _ := scrape.Named(bncRates, scrape.RateCAN)
+	us, can := usPrime.Percent, canPrime.Percent
+	bncSource := scraper(scrape.BNC).Source
+	slog.Info("scraped prime rates", "source", bncSource, "us", us, "can", can)
+	progress(stepBNC, 1, 1)
+	canRates, err := getCanadianPrimes(ctx, progress)
+	if err != nil {
+		return nil, err
 	}
-	val, err := getFedData()
+	canRates[bncSource] = can
+	others, err := getOtherRates(ctx, progress)
 	if err != nil {
-		return fmt.Errorf("error getting WSJ data: %w", err)
+		return nil, err
 	}
 
 	now := time.Now()
-	writeBNCells(f, sheet, "11", wsjDate(now), us, wsjDate(now), can)
-	writeFirst2Cells(f, sheet, "11", wsjDate(now), percent(val))
-	return nil
+	if err := writeBNCells(f, sheet, "11", wsjDate(now), us, wsjDate(now), can); err != nil {
+		return nil, err
+	}
+	if err := writeFirst2Cells(f, sheet, "11", wsjDate(now), percent(val)); err != nil {
+		return nil, err
+	}
+
+	if err := writeCanadianPrime(f, now, canRates); err != nil {
+		return nil, err
+	}
+
+	series := newSheetSeries(sheet, []string{"Wall Street", "Prime US BNC", "Prime CAN BNC"}, false, true)
+	for i, v := range []float64{val, us, can} {
+		series[i].Add(now, v)
+	}
+	for _, b := range scrape.InGroup(scrapers, scrape.GroupCanadianPrime) {
+		if rate, ok := canRates[b.Source]; ok {
+			s := &quality.Series{Sheet: sheet, Name: "Prime CAN " + b.Source, Percent: true}
+			s.Add(now, rate)
+			series = append(series, s)
+		}
+	}
+	bncSeries, err := writeBNCRates(f, now, bncRates)
+	if err != nil {
+		return nil, err
+	}
+	series = append(series, bncSeries...)
+	if len(others) > 0 {
+		otherSeries, err := writeOtherRates(f, now, others)
+		if err != nil {
+			return nil, err
+		}
+		series = append(series, otherSeries...)
+	}
+	return series, nil
 }
 
 func wsjDate(date time.Time) string {
 	return fmt.Sprintf("%d-%s-%s", date.Day(), date.Month().String()[:3], strconv.Itoa(date.Year())[2:])
 }
 
-func writeBNCells(f *excelize.File, sheet, line, v1 string, us float64, v3 string, can float64) {
+func writeBNCells(f *excelize.File, sheet, line, v1 string, us float64, v3 string, can float64) error {
 	u := percent(us)
 	c := percent(can)
-	_ = f.SetCellValue(sheet, "G"+line, v1)
-	_ = f.SetCellValue(sheet, "H"+line, u)
-	_ = f.SetCellValue(sheet, "J"+line, v3)
-	_ = f.SetCellValue(sheet, "K"+line, c)
+	if err := setCell(f, sheet, "G"+line, v1); err != nil {
+		return err
+	}
+	if err := setCell(f, sheet, "H"+line, u); err != nil {
+		return err
+	}
+	if err := setCell(f, sheet, "J"+line, v3); err != nil {
+		return err
+	}
+	return setCell(f, sheet, "K"+line, c)
 }
 
 func percent(us float64) string {
 	return fmt.Sprintf("%.2f", us) + "%"
 }
 
-func writeFirst2Cells(f *excelize.File, sheet, line, v1, v2 string) {
-	_ = f.SetCellValue(sheet, "A"+line, v1)
-	_ = f.SetCellValue(sheet, "B"+line, v2)
+func writeFirst2Cells(f *excelize.File, sheet, line, v1, v2 string) error {
+	if err := setCell(f, sheet, "A"+line, v1); err != nil {
+		return err
+	}
+	return setCell(f, sheet, "B"+line, v2)
 }
 
-func writeOECSheet(f *excelize.File) {
-	sheet := "OEC"
+var oecColumns = []string{"1 a 3 ans", "1 an", "2 ans", "3 ans", "4 ans", "5 ans"}
+
+// writeOECSheet writes the bond yields of the Bank of Canada, followed by the configured Valet series when extra is set.
+func writeOECSheet(ctx context.Context, f *excelize.File, opts options, extra *valetData) ([]*quality.Series, error) {
+	sheet := oecSheet
 	f.SetActiveSheet(0)
 	f.SetSheetName("Sheet1", sheet)
 
 	// header
-	header := getHeader(oecHeader)
-	for i, str := range header {
-		err := f.SetCellValue(sheet, fmt.Sprintf("A%d", (i+1)), str)
-		if err != nil {
-			panic(fmt.Errorf("error setting sheet vlaue: %w", err))
+	if err := writeHeader(f, sheet, oecHeader); err != nil {
+		return nil, err
+	}
+	columns := orderedSelection(oecColumns, opts.columns[sheet])
+	titles := []interface{}{tableTitle}
+	for _, c := range columns {
+		titles = append(titles, c)
+	}
+	seriesNames := columns
+	if extra != nil {
+		for _, t := range extra.titles() {
+			titles = append(titles, t)
 		}
+		seriesNames = append(append([]string{}, columns...), extra.titles()...)
 	}
-	if err := f.SetSheetRow(sheet, "A5", &[]interface{}{"Taux en date du:", "1 a 3 ans", "1 an", "2 ans", "3 ans", "4 ans", "5 ans"}); err != nil {
-		panic(err)
+	if err := setRow(f, sheet, "A5", titles); err != nil {
+		return nil, err
 	}
 
 	date, err := boc.FormatDate(startDateOEC)
 	if err != nil {
-		panic(fmt.Errorf("invalid date format: %w", err))
+		return nil, fmt.Errorf("invalid date format: %w", err)
 	}
 	dt := parseToDate(date)
-	currDate := time.Date(dt.year, time.Month(dt.month), dt.day, 0, 0, 0, 0, time.Local)
-	now := time.Now()
+	currDate := opts.startDate(time.Date(dt.year, time.Month(dt.month), dt.day, 0, 0, 0, 0, time.Local))
+	now := opts.endDate()
 	tomorrow := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
 	tomorrow.Add(time.Hour * 25)
 	line := 6
+	series := newSheetSeries(sheet, seriesNames, true, true)
 	for {
-		data, err := getOECRowData(currDate)
+		data, err := getOECRowData(ctx, currDate)
 		if err != nil {
-			panic(fmt.Errorf("error building row: %w", err))
+			return nil, fmt.Errorf("error building row: %w", err)
+		}
+		data = selectColumns(data, oecColumns, columns)
+		if extra != nil {
+			data = append(data, extra.row(currDate)[1:]...)
 		}
-		if err := f.SetSheetRow(sheet, fmt.Sprintf("A%v", line), &data); err != nil {
-			panic(err)
+		if err := setRow(f, sheet, fmt.Sprintf("A%v", line), data); err != nil {
+			return nil, err
 		}
-		currDate = currDate.Add(24 * time.Hour)
+		collectRow(series, currDate, data)
+		currDate = currDate.AddDate(0, 0, 1)
 		line++
 		if currDate.After(now) {
 			break
 		}
 	}
+	return series, nil
 }
 
-func getOECRowData(date time.Time) ([]interface{}, error) {
-
-	obs, err := bank.GetObservationForDate(dateString(date))
-	if err != nil {
-		return []interface{}{colDateString(date), "n/a", "n/a", "n/a", "n/a", "n/a", "n/a"}, nil
+// oecTenors are the tenors of oecColumns. The Bank of Canada has no 1 year yield, the 1 year
+// column has always shown the 2 year yield.
+var oecTenors = []rates.Tenor{rates.Avg1To3, rates.Year2, rates.Year2, rates.Year3, rates.Year4, rates.Year5}
+
+func getOECRowData(ctx context.Context, date time.Time) ([]interface{}, error) {
+	row := []interface{}{colDateString(date)}
+	curve, err := rateClient.Curve(ctx, rates.OEC, date)
+	switch {
+	case errors.Is(err, rates.ErrNoObservation):
+		for range oecTenors {
+			row = append(row, "n/a")
+		}
+		return row, nil
+	case err != nil:
+		return nil, err
 	}
-	three, err := strconv.ParseFloat(obs.Yield3Year.V, 64)
-	if err != nil {
-		return nil, fmt.Errorf("invalid 3 year yield value: %s", obs.Yield3Year.V)
+	for _, tenor := range oecTenors {
+		// only the tenors of the sheet fail it, the others it does not show
+		if invalid, ok := curve.Invalid[tenor]; ok {
+			return nil, &valueError{source: bocSource, date: date, field: string(invalid.Tenor) + " yield", value: invalid.Value, err: invalid.Err}
+		}
+		v, ok := curve.Rates[tenor]
+		if !ok {
+			row = append(row, "n/a")
+			continue
+		}
+		row = append(row, fmt.Sprintf("%.4f", v/100))
 	}
+	return row, nil
+}
 
-	five, err := strconv.ParseFloat(obs.Yield5Year.V, 64)
-	if err != nil {
-		return nil, fmt.Errorf("invalid 5 year yield value: %s", obs.Yield5Year.V)
+// writeHeader writes each line of header in the first column.
+func writeHeader(f *excelize.File, sheet, header string) error {
+	for i, str := range getHeader(header) {
+		if err := setCell(f, sheet, fmt.Sprintf("A%d", (i+1)), str); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setCell(f *excelize.File, sheet, cell string, value interface{}) error {
+	if err := f.SetCellValue(sheet, cell, value); err != nil {
+		return &cellError{sheet: sheet, cell: cell, err: err}
 	}
+	return nil
+}
 
-	avg := common.Average(three, five)
-	return []interface{}{
-		colDateString(date), formatFloat(obs.Average1To3Year.V), formatFloat(obs.Yield2Year.V),
-		formatFloat(obs.Yield2Year.V), formatFloat(obs.Yield3Year.V), formatFloat(fmt.Sprintf("%f", avg)),
-		formatFloat(obs.Yield5Year.V)}, nil
+func setRow(f *excelize.File, sheet, cell string, row []interface{}) error {
+	if err := f.SetSheetRow(sheet, cell, &row); err != nil {
+		return &cellError{sheet: sheet, cell: cell, err: err}
+	}
+	return nil
 }
 
 func getHeader(header string) []string {
@@ -448,113 +829,77 @@ func parseToDate(d string) date {
 	}
 }
 
-func formatFloat(val string) string {
-	f, _ := strconv.ParseFloat(val, 64)
-	f /= 100
-	return fmt.Sprintf("%.4f", f)
+// getBNData scrapes the US and Canadian prime rates of National Bank.
+func getBNData(ctx context.Context) (us, can float64, err error) {
+	rates, err := getBNRates(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	u, _ := scrape.Named(rates, scrape.RateUS)
+	c, _ := scrape.Named(rates, scrape.RateCAN)
+	return u.Percent, c.Percent, nil
 }
 
-func getBNData() (us, can float64, err error) {
-	us, can = 0, 0
-	path := "https://www.bnc.ca/fr/taux-et-analyses/taux-dinteret-et-rendements/taux-de-base.html"
-	var resp *http.Response
-	if resp, err = http.Get(path); err != nil {
-		err = fmt.Errorf("error making request: %w", err)
-		return
-	}
-	bodyBytes, err := io.ReadAll(resp.Body)
-	defer resp.Body.Close()
+// getFedData scrapes the current WSJ prime rate.
+func getFedData(ctx context.Context) (float64, error) {
+	p, err := getWSJ(ctx)
+	return p.rate, err
+}
+
+// wsjPrime is the WSJ prime rate scraped at a run and the history of its changes published on the same page.
+type wsjPrime struct {
+	rate    float64
+	history []scrape.TableRate
+}
+
+// getWSJ scrapes the current WSJ prime rate and the history of its changes.
+// Like runScraper, a page where the rate is not found is saved in diagnosticsDir.
+func getWSJ(ctx context.Context) (wsjPrime, error) {
+	d := scraper(scrape.WSJ)
+	page, doc, err := fetchScraped(ctx, d)
 	if err != nil {
-		err = fmt.Errorf("error reading body: %w", err)
-		return
+		return wsjPrime{}, err
 	}
-	bodyStr := string(bodyBytes)
-	if resp.StatusCode != 200 {
-		err = fmt.Errorf("invalid status code: %d\nbody: %s", resp.StatusCode, bodyStr)
-		return
-	}
-	var document *goquery.Document
-	document, err = goquery.NewDocumentFromReader(strings.NewReader(bodyStr))
+	res, err := d.Run(doc)
 	if err != nil {
-		err = fmt.Errorf("failed to create document: %w", err)
-		return
-
+		saveDiagnostics(d, page, res, err)
+		return wsjPrime{}, err
 	}
-	sel := document.Find(".nbc-table tbody")
-	if sel != nil {
-		sel.Find("tr").Each(func(i int, s *goquery.Selection) {
-			if i == 0 {
-				return
-			}
-			text := strings.TrimSpace(s.Text())
-			isUS := true
-			if i == 1 && strings.Contains(text, "CA") {
-				isUS = false
-			}
-			fl := float64(0)
-			for i, r := range text {
-				if unicode.IsDigit(r) {
-					text = text[i:]
-					break
-				}
-			}
-			fl, err = strconv.ParseFloat(text, 64)
-			if err != nil {
-				err = fmt.Errorf("invalid rate: %s \n err: %w", text, err)
-				return
-			}
-			if isUS {
-				us = fl
-			} else {
-				can = fl
-			}
+	return wsjPrime{rate: res.Values[scrape.RatePrime], history: scrape.History(doc)}, nil
+}
+
+// fetchPage gets a page and records its provenance. The body of a response with an error status
+// is returned with the error.
+func fetchPage(ctx context.Context, source, path string) ([]byte, error) {
+	resp, err := fetch.Get(ctx, path)
+	if resp != nil {
+		sources.Add(provenance.Record{
+			Source:    source,
+			Unit:      "page",
+			URL:       path,
+			FetchedAt: resp.FetchedAt,
+			Status:    resp.Status,
+			Hash:      provenance.Hash(resp.Body),
 		})
 	}
-
-	if us == 0 || can == 0 {
-		err = fmt.Errorf("failed to find all rates US: %v, CAN: %v", us, can)
+	if err != nil {
+		if resp != nil {
+			return resp.Body, err
+		}
+		return nil, err
 	}
-	return
+	return resp.Body, nil
 }
 
-func getFedData() (fl float64, err error) {
-	path := "http://www.fedprimerate.com/wall_street_journal_prime_rate_history.htm"
-	var resp *http.Response
-	if resp, err = http.Get(path); err != nil {
-		return 0, fmt.Errorf("error making request: %w", err)
-	}
-	bodyBytes, err := io.ReadAll(resp.Body)
-	defer resp.Body.Close()
+// fetchDocument gets an HTML page, records its provenance and parses it.
+func fetchDocument(ctx context.Context, source, path string) (*goquery.Document, error) {
+	page, err := fetchPage(ctx, source, path)
 	if err != nil {
-		return 0, fmt.Errorf("error reading body: %w", err)
-	}
-	bodyStr := string(bodyBytes)
-	if resp.StatusCode != 200 {
-		return 0, fmt.Errorf("invalid status code: %d\nbody: %s", resp.StatusCode, bodyStr)
+		return nil, err
 	}
-	var document *goquery.Document
-	document, err = goquery.NewDocumentFromReader(strings.NewReader(bodyStr))
+	document, err := goquery.NewDocumentFromReader(bytes.NewReader(page))
 	if err != nil {
-		return 0, fmt.Errorf("failed to create document: %w", err)
+		return nil, fmt.Errorf("failed to create document: %w", err)
 	}
-	document.Find("tr").Each(func(i int, s *goquery.Selection) {
-		text := strings.TrimSpace(s.Text())
-		currentText := "(The Current U.S. Prime Rate)"
-		if !strings.Contains(text, currentText) {
-			return
-		}
-		s.Find("td").Each(func(j int, s *goquery.Selection) {
-			if j != 1 {
-				return
-			}
-			text := s.Text()
-			text = strings.TrimSpace(strings.ReplaceAll(text, currentText, ""))
-			fl, err = strconv.ParseFloat(text, 64)
-			if err != nil {
-				err = fmt.Errorf("invalid wsj rate: %s \n err: %w", text, err)
-				return
-			}
-		})
-	})
-	return fl, nil
+	return document, nil
 }
@@ -1,9 +1,23 @@
 package main
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
+
+	"fyne.io/fyne/v2"
+	"github.com/clauderoy790/boc-excel-file-maker/aggregate"
+	"github.com/clauderoy790/boc-excel-file-maker/quality"
+	"github.com/clauderoy790/boc-excel-file-maker/scrape"
+	"github.com/clauderoy790/boc-excel-file-maker/valet"
+	"github.com/xuri/excelize/v2"
 )
 
 func Test_getBNData(t *testing.T) {
@@ -21,7 +35,7 @@ func Test_getBNData(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotUs, gotCan, err := getBNData()
+			gotUs, gotCan, err := getBNData(context.Background())
 			if (err != nil) != tt.wantErr {
 				t.Errorf("getBNData() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -49,7 +63,7 @@ func Test_getFedData(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotFl, err := getFedData()
+			gotFl, err := getFedData(context.Background())
 			if (err != nil) != tt.wantErr {
 				t.Errorf("getFedData() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -105,21 +119,756 @@ func Test_percent(t *testing.T) {
 
 func Test_toDate(t *testing.T) {
 	tests := []struct {
-		name string
-		dt   string
-		want time.Time
+		name    string
+		dt      string
+		want    time.Time
+		wantErr bool
 	}{
 		{
 			name: "success",
 			dt:   "4-May-22",
 			want: time.Date(2022, time.May, 4, 0, 0, 0, 0, time.Local),
 		},
+		{
+			name:    "empty",
+			dt:      "",
+			wantErr: true,
+		},
+		{
+			name:    "invalid month",
+			dt:      "4-Foo-22",
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := toDate(tt.dt); !reflect.DeepEqual(got, tt.want) {
+			got, err := toDate(tt.dt)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("toDate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("toDate() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
+
+func Test_selectColumns(t *testing.T) {
+	tests := []struct {
+		name     string
+		row      []interface{}
+		columns  []string
+		selected []string
+		want     []interface{}
+	}{
+		{
+			name:     "all",
+			row:      []interface{}{"5/2/2022", "0.0273", "0.0293"},
+			columns:  []string{"2 ans", "3 ans"},
+			selected: []string{"3 ans", "2 ans"},
+			want:     []interface{}{"5/2/2022", "0.0273", "0.0293"},
+		},
+		{
+			name:     "some",
+			row:      []interface{}{"5/2/2022", "0.0273", "0.0293", "0.0301"},
+			columns:  []string{"2 ans", "3 ans", "5 ans"},
+			selected: []string{"5 ans", "2 ans"},
+			want:     []interface{}{"5/2/2022", "0.0273", "0.0301"},
+		},
+		{
+			name:     "none",
+			row:      []interface{}{"5/2/2022", "0.0273"},
+			columns:  []string{"2 ans"},
+			selected: []string{},
+			want:     []interface{}{"5/2/2022"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := selectColumns(tt.row, tt.columns, tt.selected); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("selectColumns() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_optionsValidate(t *testing.T) {
+	start := time.Date(2022, time.May, 4, 0, 0, 0, 0, time.Local)
+	tests := []struct {
+		name    string
+		opts    func(o *options)
+		wantErr bool
+	}{
+		{
+			name: "default",
+			opts: func(o *options) {},
+		},
+		{
+			name: "range",
+			opts: func(o *options) {
+				o.start = start
+				o.end = start.AddDate(0, 1, 0)
+			},
+		},
+		{
+			name: "end before start",
+			opts: func(o *options) {
+				o.start = start
+				o.end = start.AddDate(0, 0, -1)
+			},
+			wantErr: true,
+		},
+		{
+			name:    "no output",
+			opts:    func(o *options) { o.output = "" },
+			wantErr: true,
+		},
+		{
+			name:    "no sheet",
+			opts:    func(o *options) { o.sheets = nil },
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := defaultOptions()
+			tt.opts(&o)
+			if err := o.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_monthsBetween(t *testing.T) {
+	tests := []struct {
+		name  string
+		start time.Time
+		end   time.Time
+		want  int
+	}{
+		{
+			name:  "same month",
+			start: time.Date(2022, time.May, 4, 0, 0, 0, 0, time.Local),
+			end:   time.Date(2022, time.May, 30, 0, 0, 0, 0, time.Local),
+			want:  1,
+		},
+		{
+			name:  "years",
+			start: time.Date(2015, time.June, 19, 0, 0, 0, 0, time.Local),
+			end:   time.Date(2022, time.May, 1, 0, 0, 0, 0, time.Local),
+			want:  84,
+		},
+		{
+			name:  "end before start",
+			start: time.Date(2022, time.May, 4, 0, 0, 0, 0, time.Local),
+			end:   time.Date(2022, time.April, 4, 0, 0, 0, 0, time.Local),
+			want:  0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := monthsBetween(tt.start, tt.end); got != tt.want {
+				t.Errorf("monthsBetween() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_chartPoints(t *testing.T) {
+	s := &quality.Series{}
+	s.Add(time.Date(2022, time.May, 2, 0, 0, 0, 0, time.UTC), 2)
+	s.Add(time.Date(2022, time.May, 3, 0, 0, 0, 0, time.UTC), 4)
+	s.Add(time.Date(2022, time.May, 4, 0, 0, 0, 0, time.UTC), 3)
+	b, ok := newChartBounds([]*quality.Series{s})
+	if !ok {
+		t.Fatalf("newChartBounds() found no observations")
+	}
+	tests := []struct {
+		name string
+		size fyne.Size
+		want []fyne.Position
+	}{
+		{
+			name: "one point per observation",
+			size: fyne.NewSize(100, 50),
+			want: []fyne.Position{fyne.NewPos(0, 50), fyne.NewPos(50, 0), fyne.NewPos(100, 25)},
+		},
+		{
+			name: "one point per pixel",
+			size: fyne.NewSize(1, 50),
+			want: []fyne.Position{fyne.NewPos(0.5, 0), fyne.NewPos(1, 25)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := chartPoints(s, b, tt.size); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("chartPoints() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_treasRow(t *testing.T) {
+	date := time.Date(2023, time.February, 2, 0, 0, 0, 0, time.Local)
+	values := []float64{4.58, 0, 4.7}
+	got := treasRow(date, len(values), func(i int) (float64, bool) {
+		return values[i], values[i] != 0
+	})
+	want := []interface{}{colDateString(date), "0.0458", "n/a", "0.0470"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("treasRow() = %v, want %v", got, want)
+	}
+	if len(treasDatasets) != 5 || treasDatasets[0].sheet != treasSheet {
+		t.Errorf("treasDatasets should start with %s", treasSheet)
+	}
+	for _, ds := range treasDatasets {
+		if !contains(allSheets, ds.sheet) {
+			t.Errorf("sheet %s is not in allSheets", ds.sheet)
+		}
+	}
+}
+
+func Test_valetConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     valetConfig
+		wantErr bool
+	}{
+		{
+			name: "columns and groups",
+			cfg: valetConfig{Sheets: []valetSheet{
+				{Name: oecSheet, Columns: []valetColumn{{Series: "BD.CDN.10YR.DQ.YLD"}}},
+				{Name: "BoC Benchmarks", Group: "bond_yields_benchmark"},
+			}},
+		},
+		{
+			name:    "no name",
+			cfg:     valetConfig{Sheets: []valetSheet{{Group: "bond_yields_benchmark"}}},
+			wantErr: true,
+		},
+		{
+			name:    "no series",
+			cfg:     valetConfig{Sheets: []valetSheet{{Name: "BoC"}}},
+			wantErr: true,
+		},
+		{
+			name:    "replaces a sheet",
+			cfg:     valetConfig{Sheets: []valetSheet{{Name: wsjSheet, Group: "bond_yields_benchmark"}}},
+			wantErr: true,
+		},
+		{
+			name:    "group in OEC",
+			cfg:     valetConfig{Sheets: []valetSheet{{Name: oecSheet, Group: "bond_yields_benchmark"}}},
+			wantErr: true,
+		},
+		{
+			name: "twice",
+			cfg: valetConfig{Sheets: []valetSheet{
+				{Name: "BoC", Group: "bond_yields_benchmark"},
+				{Name: "BoC", Group: "bond_yields_all"},
+			}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.cfg.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_insertBefore(t *testing.T) {
+	got := insertBefore([]string{oecSheet, wsjSheet}, wsjSheet, "BoC")
+	if want := []string{oecSheet, "BoC", wsjSheet}; !reflect.DeepEqual(got, want) {
+		t.Errorf("insertBefore() = %v, want %v", got, want)
+	}
+	got = insertBefore([]string{oecSheet}, wsjSheet, "BoC")
+	if want := []string{oecSheet, "BoC"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("insertBefore() = %v, want %v", got, want)
+	}
+}
+
+func Test_writeCanadianPrime(t *testing.T) {
+	defer func(s []scrape.Definition) { scrapers = s }(scrapers)
+	for _, bank := range []string{"RBC", "TD", "BMO", "Scotiabank", "CIBC"} {
+		scrapers = append(scrapers, scrape.Definition{Name: strings.ToLower(bank), Source: bank, Group: scrape.GroupCanadianPrime})
+	}
+	f := excelize.NewFile()
+	f.NewSheet(wsjSheet)
+	date := time.Date(2023, time.June, 8, 0, 0, 0, 0, time.Local)
+	rates := map[string]float64{"Banque Nationale": 6.7, "RBC": 6.7, "TD": 6.7, "BMO": 6.7, "CIBC": 6.95}
+	if err := writeCanadianPrime(f, date, rates); err != nil {
+		t.Fatalf("writeCanadianPrime() error = %v", err)
+	}
+	tests := []struct {
+		cell string
+		want string
+	}{
+		{"M2", "Taux en date du: 8-Jun-23"},
+		{"M5", "Banque Nationale"},
+		{"N5", "6.70%"},
+		{"O5", "6.70%"},
+		{"P5", ""},
+		{"N9", "n/a"},
+		{"M10", "CIBC"},
+		{"P10", "Diverge (+0.25%)"},
+		{"O11", "6.70%"},
+		{"P11", "4 banques sur 5"},
+	}
+	for _, tt := range tests {
+		if got, _ := f.GetCellValue(wsjSheet, tt.cell); got != tt.want {
+			t.Errorf("cell %s = %q, want %q", tt.cell, got, tt.want)
+		}
+	}
+}
+
+func Test_rateAt(t *testing.T) {
+	day := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, time.Local) }
+	history := []scrape.TableRate{
+		{Percent: 7.75, Effective: day(2023, time.February, 2)},
+		{Percent: 8.00, Effective: day(2023, time.March, 23)},
+		{Percent: 8.25, Effective: day(2023, time.May, 4)},
+	}
+	tests := []struct {
+		name   string
+		wsj    wsjPrime
+		date   time.Time
+		want   float64
+		wantOk bool
+	}{
+		{"before the history", wsjPrime{8.25, history}, day(2023, time.February, 1), 0, false},
+		{"day of a change", wsjPrime{8.25, history}, day(2023, time.March, 23), 8.00, true},
+		{"between changes", wsjPrime{8.25, history}, day(2023, time.April, 1), 8.00, true},
+		{"today, last of the history", wsjPrime{8.25, history}, time.Now(), 8.25, true},
+		{"last change of the history before a change not in it", wsjPrime{8.50, history}, day(2023, time.May, 4), 8.25, true},
+		{"after the history, day of the change unknown", wsjPrime{8.50, history}, day(2023, time.June, 1), 0, false},
+		{"today, not in the history", wsjPrime{8.50, history}, time.Now(), 8.50, true},
+		{"no history", wsjPrime{8.50, nil}, day(2023, time.June, 1), 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := rateAt(primeChanges(tt.wsj), tt.date)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("rateAt() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func Test_writeFXSheet(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"seriesDetail":{"FXUSDCAD":{"label":"USD/CAD","description":"US dollar to Canadian dollar daily exchange rate"}},
+"observations":[{"d":"2022-05-02","FXUSDCAD":{"v":"1.2851"}},{"d":"2022-05-04","FXUSDCAD":{"v":"1.2797"}}]}`))
+	}))
+	defer srv.Close()
+	base := valet.BaseURL
+	valet.BaseURL = srv.URL
+	defer func() { valet.BaseURL = base }()
+
+	opts := defaultOptions()
+	opts.start = time.Date(2022, time.May, 2, 0, 0, 0, 0, time.Local)
+	opts.end = time.Date(2022, time.May, 4, 0, 0, 0, 0, time.Local)
+	f := excelize.NewFile()
+	series, err := writeFXSheet(context.Background(), f, opts, func(string, int, int) {})
+	if err != nil {
+		t.Fatalf("writeFXSheet() error = %v", err)
+	}
+	tests := []struct {
+		cell string
+		want string
+	}{
+		{"B5", "USD/CAD"},
+		{"B6", "1.2851"},
+		{"B7", "n/a"},
+		{"B8", "1.2797"},
+	}
+	for _, tt := range tests {
+		if got, _ := f.GetCellValue(fxSheet, tt.cell); got != tt.want {
+			t.Errorf("cell %s = %q, want %q", tt.cell, got, tt.want)
+		}
+	}
+	if len(series) != 1 || len(series[0].Observations) != 2 || series[0].Config == nil {
+		t.Errorf("writeFXSheet() series = %v", series)
+	}
+}
+
+func Test_runScraperDiagnostics(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<table class="nbc-table"><tbody><tr><td>Taux de base US</td><td>8.25</td></tr></tbody></table>`))
+	}))
+	defer srv.Close()
+	oldDir := diagnosticsDir
+	defer func() { diagnosticsDir = oldDir }()
+	diagnosticsDir = t.TempDir()
+
+	d := scraper(scrape.BNC)
+	d.URL = srv.URL
+	if _, err := runScraper(context.Background(), d); err == nil {
+		t.Fatal("runScraper() error = nil, want the missing CA rate")
+	}
+	pages, _ := filepath.Glob(filepath.Join(diagnosticsDir, "bnc-*.html"))
+	if len(pages) != 1 {
+		t.Fatalf("saved pages = %v, want 1", pages)
+	}
+	var out strings.Builder
+	if err := replayScraper(&out, scrape.BNC, pages[0]); err == nil {
+		t.Error("replayScraper() error = nil, want the missing CA rate")
+	}
+	if !strings.Contains(out.String(), "can: not found") || !strings.Contains(out.String(), "us: 8.2500") {
+		t.Errorf("replayScraper() report = %s", out.String())
+	}
+}
+
+func Test_runScraperStatusDiagnostics(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`<html><body>Access denied</body></html>`))
+	}))
+	defer srv.Close()
+	oldDir := diagnosticsDir
+	defer func() { diagnosticsDir = oldDir }()
+	diagnosticsDir = t.TempDir()
+
+	d := scraper(scrape.WSJ)
+	d.URL = srv.URL
+	if _, err := runScraper(context.Background(), d); err == nil {
+		t.Fatal("runScraper() error = nil, want the 403")
+	}
+	pages, _ := filepath.Glob(filepath.Join(diagnosticsDir, "wsj-*.html"))
+	if len(pages) != 1 {
+		t.Fatalf("saved pages = %v, want 1", pages)
+	}
+	if page, _ := os.ReadFile(pages[0]); !strings.Contains(string(page), "Access denied") {
+		t.Errorf("saved page = %s, want the body of the 403", page)
+	}
+	report, _ := os.ReadFile(strings.TrimSuffix(pages[0], ".html") + ".txt")
+	if !strings.Contains(string(report), "403") {
+		t.Errorf("report = %s, want the status", report)
+	}
+}
+
+func Test_writeBNCRates(t *testing.T) {
+	f := excelize.NewFile()
+	f.NewSheet(wsjSheet)
+	date := time.Date(2023, time.June, 8, 0, 0, 0, 0, time.Local)
+	rates := []scrape.TableRate{
+		{Label: "Taux préférentiel CA", Currency: scrape.CAD, Percent: 6.7, Effective: date, Published: "8 juin 2023"},
+		{Label: "Taux d'escompte", Percent: 4.75, Published: "bientôt"},
+	}
+	series, err := writeBNCRates(f, date, rates)
+	if err != nil {
+		t.Fatalf("writeBNCRates() error = %v", err)
+	}
+	for cell, want := range map[string]string{
+		"V5": "Taux préférentiel CA", "W5": "CAD", "X5": "6.70%", "Y5": "8-Jun-23",
+		"W6": "n/a", "X6": "4.75%", "Y6": "bientôt",
+	} {
+		if got, _ := f.GetCellValue(wsjSheet, cell); got != want {
+			t.Errorf("cell %s = %q, want %q", cell, got, want)
+		}
+	}
+	if len(series) != 2 || series[1].Name != "BNC Taux d'escompte" {
+		t.Errorf("writeBNCRates() series = %v", series)
+	}
+}
+
+func Test_slug(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"5 ans", "5y"},
+		{"1 an", "1y"},
+		{"1 a 3 ans", "1_a_3y"},
+		{"13 sem.", "13w"},
+		{"USD/CAD", "usd_cad"},
+		{"Composite > 10 ans", "composite_10y"},
+		{"Écart prime - fed funds", "ecart_prime_fed_funds"},
+	}
+	for _, tt := range tests {
+		if got := slug(tt.title); got != tt.want {
+			t.Errorf("slug(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+	}
+}
+
+func Test_templateSources(t *testing.T) {
+	day := time.Date(2023, time.June, 8, 0, 0, 0, 0, time.Local)
+	oec := &quality.Series{Sheet: oecSheet, Name: "5 ans", Percent: true}
+	oec.Add(day, 3.5)
+	fx := &quality.Series{Sheet: fxSheet, Name: "USD/CAD", Config: &quality.Config{}}
+	fx.Add(day, 1.33)
+	// a rate with a quality configuration of its own is still in percent
+	policy := &quality.Series{Sheet: policySheet, Name: "SOFR", Percent: true, Config: &quality.Config{}}
+	policy.Add(day, 5.3)
+	sources := templateSources([]*quality.Series{oec, fx, policy})
+	if len(sources) != 3 || sources[0].Key != "oec" || sources[1].Key != "fx" {
+		t.Fatalf("templateSources() = %v", sources)
+	}
+	if s := sources[0].Series[0]; s.Key != "5y" || s.Observations[0].Value != 0.035 {
+		t.Errorf("OEC series = %v, want 5y at 0.035", s)
+	}
+	if s := sources[1].Series[0]; s.Key != "usd_cad" || s.Observations[0].Value != 1.33 {
+		t.Errorf("FX series = %v, want usd_cad at 1.33", s)
+	}
+	if s := sources[2].Series[0]; s.Observations[0].Value != 0.053 {
+		t.Errorf("policy series = %v, want 0.053", s)
+	}
+}
+
+func Test_saveInto(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.xlsx")
+	dst := excelize.NewFile()
+	dst.SetSheetName("Sheet1", "Mes calculs")
+	dst.SetCellFormula("Mes calculs", "A1", "'OEC'!B2*100")
+	dst.NewSheet(oecSheet)
+	dst.SetSheetRow(oecSheet, "A1", &[]interface{}{"Date", "5 ans"})
+	dst.SetSheetRow(oecSheet, "A2", &[]interface{}{"2023-06-07", "0.0340", "old"})
+	dst.SetSheetRow(oecSheet, "A3", &[]interface{}{"2023-06-06", "0.0330"})
+	dst.SetCellFormula(oecSheet, "B3", "B2")
+	dst.SetDefinedName(&excelize.DefinedName{Name: "oec_5y", RefersTo: "'OEC'!$B$2:$B$3"})
+	if err := dst.SaveAs(path); err != nil {
+		t.Fatal(err)
+	}
+
+	src := excelize.NewFile()
+	src.SetSheetName("Sheet1", oecSheet)
+	src.SetSheetRow(oecSheet, "A1", &[]interface{}{"Date", "5 ans"})
+	src.SetSheetRow(oecSheet, "A2", &[]interface{}{"2023-06-08", 0.035})
+	src.NewSheet(wsjSheet)
+	src.SetCellValue(wsjSheet, "A1", "Wall Street #45")
+	src.SetDefinedName(&excelize.DefinedName{Name: "OEC_5Y", RefersTo: "'OEC'!$B$2"})
+	wb := &workbook{file: src}
+	if err := wb.saveInto(path); err != nil {
+		t.Fatalf("saveInto() error = %v", err)
+	}
+
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := f.GetSheetList(), []string{"Mes calculs", oecSheet, wsjSheet}; !reflect.DeepEqual(got, want) {
+		t.Errorf("sheets = %v, want %v", got, want)
+	}
+	if formula, _ := f.GetCellFormula("Mes calculs", "A1"); formula != "'OEC'!B2*100" {
+		t.Errorf("user formula = %q", formula)
+	}
+	if formula, _ := f.GetCellFormula(oecSheet, "B3"); formula != "" {
+		t.Errorf("OEC formula = %q, want none", formula)
+	}
+	rows, _ := f.GetRows(oecSheet)
+	if want := [][]string{{"Date", "5 ans"}, {"2023-06-08", "0.035"}}; !reflect.DeepEqual(rows, want) {
+		t.Errorf("OEC rows = %v, want %v", rows, want)
+	}
+	if typ, _ := f.GetCellType(oecSheet, "B2"); typ == excelize.CellTypeString {
+		t.Errorf("B2 type = %v, want a number", typ)
+	}
+	if names := f.GetDefinedName(); len(names) != 1 || names[0].Name != "OEC_5Y" || names[0].RefersTo != "'OEC'!$B$2" {
+		t.Errorf("defined names = %v, want OEC_5Y replaced", names)
+	}
+}
+
+func Test_addDefinedNames(t *testing.T) {
+	f := excelize.NewFile()
+	f.SetSheetName("Sheet1", oecSheet)
+	f.SetSheetRow(oecSheet, "A5", &[]interface{}{tableTitle, "1 an", "5 ans"})
+	f.SetSheetRow(oecSheet, "A6", &[]interface{}{"2023-06-07", "0.0450", "0.0340"})
+	f.SetSheetRow(oecSheet, "A7", &[]interface{}{"2023-06-08", "0.0460", "n/a"})
+	f.NewSheet(wsjSheet)
+	f.SetCellValue(wsjSheet, "A5", "5-May-22")
+	if err := addDefinedNames(f); err != nil {
+		t.Fatalf("addDefinedNames() error = %v", err)
+	}
+	got := make(map[string]string)
+	for _, dn := range f.GetDefinedName() {
+		got[dn.Name] = dn.RefersTo
+	}
+	want := map[string]string{
+		"OEC_Date":      "'OEC'!$A$6:$A$7",
+		"OEC_1Y":        "'OEC'!$B$6:$B$7",
+		"OEC_1Y_Latest": "'OEC'!$B$7",
+		"OEC_5Y":        "'OEC'!$C$6:$C$7",
+		"OEC_5Y_Latest": "'OEC'!$C$6",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("defined names = %v, want %v", got, want)
+	}
+}
+
+func Test_typeDailyCells(t *testing.T) {
+	f := excelize.NewFile()
+	f.SetSheetName("Sheet1", oecSheet)
+	f.SetSheetRow(oecSheet, "A5", &[]interface{}{tableTitle, "1 an", "5 ans"})
+	f.SetSheetRow(oecSheet, "A6", &[]interface{}{colDateString(time.Date(2023, time.June, 7, 0, 0, 0, 0, time.Local)), "0.0450", "0.0340"})
+	f.SetSheetRow(oecSheet, "A7", &[]interface{}{colDateString(time.Date(2023, time.June, 8, 0, 0, 0, 0, time.Local)), "0.0460", "n/a"})
+	// a second pass, as when updating a workbook, leaves the cells as they are
+	for i := 0; i < 2; i++ {
+		if err := typeDailyCells(f, oecSheet); err != nil {
+			t.Fatalf("typeDailyCells() error = %v", err)
+		}
+	}
+	if err := addDefinedNames(f); err != nil {
+		t.Fatalf("addDefinedNames() error = %v", err)
+	}
+
+	// the first cell behind each name
+	cells := make(map[string]string)
+	for _, dn := range f.GetDefinedName() {
+		ref := strings.ReplaceAll(dn.RefersTo[strings.Index(dn.RefersTo, "!")+1:], "$", "")
+		cells[dn.Name] = strings.Split(ref, ":")[0]
+	}
+	raw, _ := f.GetCellValue(oecSheet, cells["OEC_Date"], excelize.Options{RawCellValue: true})
+	serial, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		t.Fatalf("OEC_Date cell = %q, want a date serial", raw)
+	}
+	if d, _ := excelize.ExcelDateToTime(serial, false); !d.Equal(time.Date(2023, time.June, 7, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("OEC_Date = %v, want 2023-06-07", d)
+	}
+	if got, _ := f.GetCellValue(oecSheet, cells["OEC_Date"]); got != "6/7/2023" {
+		t.Errorf("OEC_Date shown as %q, want 6/7/2023", got)
+	}
+	for name, want := range map[string]float64{"OEC_1Y": 0.045, "OEC_1Y_Latest": 0.046, "OEC_5Y": 0.034} {
+		if typ, _ := f.GetCellType(oecSheet, cells[name]); typ == excelize.CellTypeString {
+			t.Errorf("%s is text, want a number", name)
+		}
+		raw, _ := f.GetCellValue(oecSheet, cells[name], excelize.Options{RawCellValue: true})
+		if v, err := strconv.ParseFloat(raw, 64); err != nil || v != want {
+			t.Errorf("%s = %q, want %v", name, raw, want)
+		}
+	}
+	if got, _ := f.GetCellValue(oecSheet, "C7"); got != "n/a" {
+		t.Errorf("missing value = %q, want n/a", got)
+	}
+}
+
+func Test_writeAggregateSheets(t *testing.T) {
+	f := excelize.NewFile()
+	oec := &quality.Series{Sheet: oecSheet, Name: "5 ans", Daily: true, Percent: true}
+	oec.Add(time.Date(2023, time.May, 31, 0, 0, 0, 0, time.Local), 3.0)
+	oec.Add(time.Date(2023, time.June, 1, 0, 0, 0, 0, time.Local), 3.5)
+	oec.Add(time.Date(2023, time.June, 2, 0, 0, 0, 0, time.Local), 4.5)
+	fx := &quality.Series{Sheet: fxSheet, Name: "USD/CAD", Daily: true, Config: &quality.Config{}}
+	fx.Add(time.Date(2023, time.June, 1, 0, 0, 0, 0, time.Local), 1.33)
+	prime := &quality.Series{Sheet: wsjSheet, Name: "Wall Street", Percent: true}
+	prime.Add(time.Date(2023, time.June, 1, 0, 0, 0, 0, time.Local), 8.25)
+
+	opts := defaultOptions()
+	opts.aggregates = []string{"Mensuel"}
+	if err := writeAggregateSheets(f, opts, []*quality.Series{oec, fx, prime}); err != nil {
+		t.Fatalf("writeAggregateSheets() error = %v", err)
+	}
+	if got, want := f.GetSheetList(), []string{"Sheet1", "OEC - Mensuel", "Taux de change - Mensuel"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("sheets = %v, want %v", got, want)
+	}
+	rows, _ := f.GetRows("OEC - Mensuel")
+	want := [][]string{
+		{"Début", "Fin", "5 ans moy.", "5 ans fin", "5 ans min", "5 ans max"},
+		{"5/1/2023", "5/31/2023", "0.0300", "0.0300", "0.0300", "0.0300"},
+		{"6/1/2023", "6/30/2023", "0.0400", "0.0450", "0.0350", "0.0450"},
+	}
+	if !reflect.DeepEqual(rows[4:], want) {
+		t.Errorf("OEC - Mensuel rows = %v, want %v", rows[4:], want)
+	}
+	if got, _ := f.GetCellValue("Taux de change - Mensuel", "C6"); got != "1.3300" {
+		t.Errorf("USD/CAD average = %q, want 1.3300", got)
+	}
+	if got := aggregateSheetName(realLongTermSheet, aggregate.Quarterly); len(got) > maxSheetName {
+		t.Errorf("aggregateSheetName() = %q, longer than %d", got, maxSheetName)
+	}
+}
+
+func Test_backfillDate(t *testing.T) {
+	tests := []struct {
+		s       string
+		end     bool
+		want    time.Time
+		wantErr bool
+	}{
+		{"1990", false, time.Date(1990, 1, 1, 0, 0, 0, 0, time.Local), false},
+		{"1990", true, time.Date(1990, 12, 31, 0, 0, 0, 0, time.Local), false},
+		{"2001-09-10", true, time.Date(2001, 9, 10, 0, 0, 0, 0, time.Local), false},
+		{"199", false, time.Time{}, true},
+		{"last year", false, time.Time{}, true},
+	}
+	for _, tt := range tests {
+		got, err := backfillDate(tt.s, tt.end)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("backfillDate(%q) error = %v, wantErr %v", tt.s, err, tt.wantErr)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("backfillDate(%q, %v) = %v, want %v", tt.s, tt.end, got, tt.want)
+		}
+	}
+}
+
+func Test_newPolicySeries(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2022, time.June, d, 0, 0, 0, 0, time.Local) }
+	tests := []struct {
+		name   string
+		from   float64
+		to     float64
+		errors int
+	}{
+		{"75bp hike", 0.75, 1.50, 0},
+		{"March 2020 cut", 1.25, 0.25, 0},
+		{"2 points", 1.50, 3.50, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			series := newPolicySeries()
+			for _, s := range series {
+				s.Add(day(15), tt.from)
+				s.Add(day(16), tt.to)
+			}
+			cfg := quality.DefaultConfig()
+			cfg.AsOf = day(16)
+			if got := quality.Errors(quality.Check(cfg, series)); len(got) != tt.errors {
+				t.Errorf("errors = %v, want %d", got, tt.errors)
+			}
+		})
+	}
+	var sofr *quality.Series
+	for _, s := range newPolicySeries() {
+		if s.Name == "SOFR" {
+			sofr = s
+		}
+	}
+	sofr.Add(time.Date(2019, time.September, 16, 0, 0, 0, 0, time.Local), 2.43)
+	sofr.Add(time.Date(2019, time.September, 17, 0, 0, 0, 0, time.Local), 5.25)
+	cfg := quality.DefaultConfig()
+	cfg.AsOf = time.Date(2019, time.September, 17, 0, 0, 0, 0, time.Local)
+	if got := quality.Errors(quality.Check(cfg, []*quality.Series{sofr})); len(got) != 0 {
+		t.Errorf("SOFR spike errors = %v", got)
+	}
+}
+
+func Test_qualityConfig(t *testing.T) {
+	end := time.Date(2022, time.March, 31, 0, 0, 0, 0, time.Local)
+	s := &quality.Series{Sheet: oecSheet, Name: "5 ans", Daily: true, Percent: true}
+	s.Add(end.AddDate(0, 0, -1), 2.4)
+	s.Add(end, 2.5)
+	if got := quality.Errors(quality.Check(qualityConfig(options{end: end}), []*quality.Series{s})); len(got) != 0 {
+		t.Errorf("errors with an end date in the past = %v", got)
+	}
+	if got := qualityConfig(options{}).AsOf; time.Since(got) > time.Minute {
+		t.Errorf("AsOf without an end date = %s, want now", got)
+	}
+}
+
+func Test_fxQuality(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2023, time.June, d, 0, 0, 0, 0, time.Local) }
+	s := &quality.Series{Sheet: fxSheet, Name: "USD/CAD", Daily: true, Config: fxQuality}
+	s.Add(day(7), 1.3421)
+	s.Add(day(8), 1.3385)
+	cfg := quality.DefaultConfig()
+	cfg.AsOf = day(9).Add(10 * time.Hour)
+	if got := quality.Errors(quality.Check(cfg, []*quality.Series{s})); len(got) != 0 {
+		t.Errorf("errors = %v, want none for a rate of the day before", got)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// saveInto writes the sheets of the workbook in the existing workbook at path. The sheets of
+// the workbook are updated in place so that the formulas, charts and styles that refer to them
+// keep working, the other sheets are left as they are.
+func (wb *workbook) saveInto(path string) error {
+	dst, err := excelize.OpenFile(path)
+	if err != nil {
+		return &stepError{step: stepWriting, err: fmt.Errorf("error opening %s: %w", path, err)}
+	}
+	defer dst.Close()
+	for _, sheet := range wb.file.GetSheetList() {
+		if err := replaceSheet(dst, wb.file, sheet); err != nil {
+			return &stepError{step: stepWriting, detail: sheet, err: err}
+		}
+		// the styles are not copied with the values
+		if err := typeDailyCells(dst, sheet); err != nil {
+			return &stepError{step: stepWriting, detail: sheet, err: err}
+		}
+	}
+	if err := copyDefinedNames(dst, wb.file); err != nil {
+		return &stepError{step: stepWriting, err: err}
+	}
+	slog.Info("updated workbook", "path", path, "sheets", len(wb.file.GetSheetList()), "kept", len(dst.GetSheetList())-len(wb.file.GetSheetList()))
+	if err := dst.SaveAs(path); err != nil {
+		return &stepError{step: stepWriting, err: fmt.Errorf("failed to write file: %w", err)}
+	}
+	return nil
+}
+
+// replaceSheet makes the cells of sheet in dst those of src. The cells are written one by one
+// instead of replacing the sheet, which would break the references of the other sheets to it.
+func replaceSheet(dst, src *excelize.File, sheet string) error {
+	if dst.GetSheetIndex(sheet) == -1 {
+		dst.NewSheet(sheet)
+	}
+	old, err := dst.GetRows(sheet, excelize.Options{RawCellValue: true})
+	if err != nil {
+		return fmt.Errorf("error reading existing sheet: %w", err)
+	}
+	rows, err := src.GetRows(sheet, excelize.Options{RawCellValue: true})
+	if err != nil {
+		return fmt.Errorf("error reading sheet: %w", err)
+	}
+	for r, row := range old {
+		for c, v := range row {
+			if v == "" || (r < len(rows) && c < len(rows[r]) && rows[r][c] != "") {
+				continue
+			}
+			cell, _ := excelize.CoordinatesToCellName(c+1, r+1)
+			if err := setValue(dst, sheet, cell, nil); err != nil {
+				return err
+			}
+		}
+	}
+	for r, row := range rows {
+		for c, v := range row {
+			if v == "" {
+				continue
+			}
+			cell, _ := excelize.CoordinatesToCellName(c+1, r+1)
+			var value interface{} = v
+			// numbers are written without a type
+			if typ, _ := src.GetCellType(sheet, cell); typ == excelize.CellTypeNumber || typ == excelize.CellTypeUnset {
+				if n, err := strconv.ParseFloat(v, 64); err == nil {
+					value = n
+				}
+			}
+			if err := setValue(dst, sheet, cell, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// setValue sets the value of a cell, removing a formula it had since the value replaces it.
+func setValue(f *excelize.File, sheet, cell string, value interface{}) error {
+	if formula, _ := f.GetCellFormula(sheet, cell); formula != "" {
+		if err := f.SetCellFormula(sheet, cell, ""); err != nil {
+			return fmt.Errorf("error clearing %s: %w", cell, err)
+		}
+	}
+	if err := f.SetCellValue(sheet, cell, value); err != nil {
+		return fmt.Errorf("error writing %s: %w", cell, err)
+	}
+	return nil
+}
+
+// exists tells whether there is a file at path.
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
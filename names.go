@@ -0,0 +1,182 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// tableTitle is the title of the date column of the daily sheets, whose titles are on the
+// 5th row and whose rows start on the 6th.
+const tableTitle = "Taux en date du:"
+
+const (
+	titleRow    = 5
+	firstRowNum = 6
+)
+
+// addDefinedNames names the columns of each daily sheet so formulas don't depend on row numbers:
+// OEC_Date for the dates, OEC_5Y for a column and OEC_5Y_Latest for its last value.
+func addDefinedNames(f *excelize.File) error {
+	for _, sheet := range f.GetSheetList() {
+		if title, _ := f.GetCellValue(sheet, fmt.Sprintf("A%d", titleRow)); title != tableTitle {
+			continue
+		}
+		rows, err := f.GetRows(sheet)
+		if err != nil {
+			return fmt.Errorf("error reading %s: %w", sheet, err)
+		}
+		if len(rows) < firstRowNum {
+			continue
+		}
+		last := len(rows)
+		prefix := strings.ToUpper(sheetKey(sheet)) + "_"
+		names := []excelize.DefinedName{{Name: prefix + "Date", RefersTo: columnRef(sheet, 1, firstRowNum, last)}}
+		for c, title := range rows[titleRow-1] {
+			if c == 0 || title == "" {
+				continue
+			}
+			name := prefix + strings.ToUpper(slug(title))
+			names = append(names, excelize.DefinedName{Name: name, RefersTo: columnRef(sheet, c+1, firstRowNum, last)})
+			if row, ok := latestRow(rows, c); ok {
+				names = append(names, excelize.DefinedName{Name: name + "_Latest", RefersTo: columnRef(sheet, c+1, row, row)})
+			}
+		}
+		for _, dn := range names {
+			dn := dn
+			if err := f.SetDefinedName(&dn); errors.Is(err, excelize.ErrDefinedNameDuplicate) {
+				slog.Warn("duplicate defined name", "sheet", sheet, "name", dn.Name)
+			} else if err != nil {
+				return fmt.Errorf("error naming %s: %w", dn.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// dateFormat is the format of the dates of the daily sheets, the text they were written as.
+const dateFormat = "m/d/yyyy"
+
+// minDecimals is the least number of decimals shown for the values of the daily sheets, those of the rates.
+const minDecimals = 4
+
+// typeDailyCells makes the dates of a daily sheet Excel dates and its values numbers, with a number format
+// showing them as they were written, so the names of addDefinedNames work with MATCH and the like.
+// The rows are written as text by the sheets; cells that already have their type are left as they are.
+func typeDailyCells(f *excelize.File, sheet string) error {
+	if title, _ := f.GetCellValue(sheet, fmt.Sprintf("A%d", titleRow)); title != tableTitle {
+		return nil
+	}
+	rows, err := f.GetRows(sheet, excelize.Options{RawCellValue: true})
+	if err != nil {
+		return fmt.Errorf("error reading %s: %w", sheet, err)
+	}
+	if len(rows) < firstRowNum {
+		return nil
+	}
+	decimals := make(map[int]int)
+	for r := firstRowNum - 1; r < len(rows); r++ {
+		for c, v := range rows[r] {
+			cell, _ := excelize.CoordinatesToCellName(c+1, r+1)
+			if c == 0 {
+				if d, err := time.ParseInLocation("1/2/2006", v, time.Local); err == nil {
+					if err := f.SetCellValue(sheet, cell, d); err != nil {
+						return fmt.Errorf("error writing %s: %w", cell, err)
+					}
+				}
+				continue
+			}
+			n, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			if i := strings.IndexByte(v, '.'); i >= 0 && len(v)-i-1 > decimals[c] {
+				decimals[c] = len(v) - i - 1
+			}
+			if typ, _ := f.GetCellType(sheet, cell); typ == excelize.CellTypeString {
+				if err := f.SetCellFloat(sheet, cell, n, -1, 64); err != nil {
+					return fmt.Errorf("error writing %s: %w", cell, err)
+				}
+			}
+		}
+	}
+
+	last := len(rows)
+	format := dateFormat
+	style, err := f.NewStyle(&excelize.Style{CustomNumFmt: &format})
+	if err != nil {
+		return fmt.Errorf("error creating date style: %w", err)
+	}
+	if err := f.SetCellStyle(sheet, fmt.Sprintf("A%d", firstRowNum), fmt.Sprintf("A%d", last), style); err != nil {
+		return fmt.Errorf("error styling %s dates: %w", sheet, err)
+	}
+	styles := make(map[int]int)
+	for c, n := range decimals {
+		if n < minDecimals {
+			n = minDecimals
+		}
+		if _, ok := styles[n]; !ok {
+			format := "0." + strings.Repeat("0", n)
+			if styles[n], err = f.NewStyle(&excelize.Style{CustomNumFmt: &format}); err != nil {
+				return fmt.Errorf("error creating number style: %w", err)
+			}
+		}
+		from, _ := excelize.CoordinatesToCellName(c+1, firstRowNum)
+		to, _ := excelize.CoordinatesToCellName(c+1, last)
+		if err := f.SetCellStyle(sheet, from, to, styles[n]); err != nil {
+			return fmt.Errorf("error styling %s: %w", sheet, err)
+		}
+	}
+	return nil
+}
+
+// latestRow returns the number of the last row with a value in column c.
+func latestRow(rows [][]string, c int) (int, bool) {
+	for r := len(rows) - 1; r >= firstRowNum-1; r-- {
+		if c < len(rows[r]) && rows[r][c] != "" && rows[r][c] != "n/a" {
+			return r + 1, true
+		}
+	}
+	return 0, false
+}
+
+// columnRef returns an absolute reference to the rows from first to last of a column.
+func columnRef(sheet string, col, first, last int) string {
+	from, _ := excelize.CoordinatesToCellName(col, first, true)
+	ref := "'" + strings.ReplaceAll(sheet, "'", "''") + "'!" + from
+	if last != first {
+		to, _ := excelize.CoordinatesToCellName(col, last, true)
+		ref += ":" + to
+	}
+	return ref
+}
+
+// copyDefinedNames sets the workbook names of src in dst, replacing those with the same name.
+func copyDefinedNames(dst, src *excelize.File) error {
+	// names are not case sensitive
+	existing := make(map[string]string)
+	for _, dn := range dst.GetDefinedName() {
+		if dn.Scope == "Workbook" {
+			existing[strings.ToUpper(dn.Name)] = dn.Name
+		}
+	}
+	for _, dn := range src.GetDefinedName() {
+		if dn.Scope != "Workbook" {
+			continue
+		}
+		if name, ok := existing[strings.ToUpper(dn.Name)]; ok {
+			if err := dst.DeleteDefinedName(&excelize.DefinedName{Name: name}); err != nil {
+				return fmt.Errorf("error replacing %s: %w", dn.Name, err)
+			}
+		}
+		if err := dst.SetDefinedName(&excelize.DefinedName{Name: dn.Name, RefersTo: dn.RefersTo, Comment: dn.Comment}); err != nil {
+			return fmt.Errorf("error naming %s: %w", dn.Name, err)
+		}
+	}
+	return nil
+}
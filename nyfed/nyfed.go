@@ -0,0 +1,172 @@
+// Package nyfed reads the reference rates published by the Federal Reserve Bank of New York:
+// the effective federal funds rate with the FOMC target range, and SOFR.
+package nyfed
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/clauderoy790/boc-excel-file-maker/fetch"
+	"github.com/clauderoy790/boc-excel-file-maker/provenance"
+)
+
+// BaseURL is the address of the markets API of the New York Fed.
+var BaseURL = "https://markets.newyorkfed.org/api/rates"
+
+// SourceName is the name used for the New York Fed in provenance records.
+const SourceName = "Federal Reserve Bank of New York"
+
+const dateLayout = "2006-01-02"
+
+// Kind is a reference rate, as in the paths of the API.
+type Kind string
+
+const (
+	EFFR Kind = "unsecured/effr"
+	SOFR Kind = "secured/sofr"
+)
+
+// Rate is the rate published for a business day. The target range of the FOMC is only
+// published with the EFFR.
+type Rate struct {
+	Date    time.Time
+	Type    string
+	Percent float64
+	// TargetFrom and TargetTo are the bounds of the federal funds target range, when HasTarget.
+	TargetFrom float64
+	TargetTo   float64
+	HasTarget  bool
+	Volume     float64
+	Revised    bool
+}
+
+// Rates are the published rates of a kind, by date.
+type Rates struct {
+	Kind  Kind
+	rates map[string]Rate
+	// Source tells where the rates came from.
+	Source provenance.Record
+}
+
+// Get returns the rate of date and whether one was published that day.
+func (r *Rates) Get(date time.Time) (Rate, bool) {
+	rate, ok := r.rates[date.Format(dateLayout)]
+	return rate, ok
+}
+
+// All returns the rates by date.
+func (r *Rates) All() []Rate {
+	all := make([]Rate, 0, len(r.rates))
+	for _, rate := range r.rates {
+		all = append(all, rate)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].Date.Before(all[j].Date)
+	})
+	return all
+}
+
+type response struct {
+	RefRates []struct {
+		EffectiveDate     string   `json:"effectiveDate"`
+		Type              string   `json:"type"`
+		PercentRate       *float64 `json:"percentRate"`
+		TargetRateFrom    *float64 `json:"targetRateFrom"`
+		TargetRateTo      *float64 `json:"targetRateTo"`
+		VolumeInBillions  float64  `json:"volumeInBillions"`
+		RevisionIndicator string   `json:"revisionIndicator"`
+	} `json:"refRates"`
+}
+
+// Fetch returns the rates of a kind published from start to end, both included.
+func Fetch(ctx context.Context, kind Kind, start, end time.Time) (*Rates, error) {
+	query := url.Values{}
+	query.Set("startDate", start.Format(dateLayout))
+	query.Set("endDate", end.Format(dateLayout))
+	u := fmt.Sprintf("%s/%s/search.json?%s", BaseURL, kind, query.Encode())
+	r := &Rates{
+		Kind:   kind,
+		rates:  make(map[string]Rate),
+		Source: provenance.Record{Source: SourceName, Unit: string(kind), URL: u},
+	}
+	resp, err := fetch.Get(ctx, u)
+	if resp != nil {
+		r.Source.FetchedAt = resp.FetchedAt
+		r.Source.Status = resp.Status
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching %s: %w", kind, err)
+	}
+	r.Source.Hash = provenance.Hash(resp.Body)
+	if err := r.parse(resp.Body); err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", kind, err)
+	}
+	return r, nil
+}
+
+func (r *Rates) parse(body []byte) error {
+	var resp response
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return fmt.Errorf("error while unmarshalling: %w", err)
+	}
+	for _, ref := range resp.RefRates {
+		d, err := time.ParseInLocation(dateLayout, ref.EffectiveDate, time.Local)
+		if err != nil {
+			return fmt.Errorf("invalid effective date %q: %w", ref.EffectiveDate, err)
+		}
+		// a day without a rate is not published yet
+		if ref.PercentRate == nil {
+			continue
+		}
+		rate := Rate{
+			Date:    d,
+			Type:    ref.Type,
+			Percent: *ref.PercentRate,
+			Volume:  ref.VolumeInBillions,
+			Revised: ref.RevisionIndicator != "",
+		}
+		if ref.TargetRateFrom != nil && ref.TargetRateTo != nil {
+			rate.TargetFrom, rate.TargetTo, rate.HasTarget = *ref.TargetRateFrom, *ref.TargetRateTo, true
+		}
+		r.rates[ref.EffectiveDate] = rate
+	}
+	return nil
+}
+
+// TargetRange is a federal funds target range set by the FOMC, in effect from Date.
+type TargetRange struct {
+	Date time.Time
+	From float64
+	To   float64
+}
+
+// TargetRanges returns the history of the target range found in rates: the first range
+// and each change after it.
+func TargetRanges(rates *Rates) []TargetRange {
+	var ranges []TargetRange
+	for _, rate := range rates.All() {
+		if !rate.HasTarget {
+			continue
+		}
+		if n := len(ranges); n > 0 && ranges[n-1].From == rate.TargetFrom && ranges[n-1].To == rate.TargetTo {
+			continue
+		}
+		ranges = append(ranges, TargetRange{Date: rate.Date, From: rate.TargetFrom, To: rate.TargetTo})
+	}
+	return ranges
+}
+
+// RangeAt returns the target range in effect on date, the last one set on or before it.
+func RangeAt(ranges []TargetRange, date time.Time) (TargetRange, bool) {
+	i := sort.Search(len(ranges), func(i int) bool {
+		return ranges[i].Date.After(date)
+	})
+	if i == 0 {
+		return TargetRange{}, false
+	}
+	return ranges[i-1], true
+}
@@ -0,0 +1,82 @@
+package nyfed
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// serveNYFed serves the fixtures of testdata in place of the markets API.
+func serveNYFed(t *testing.T) {
+	files := map[string]string{
+		"/unsecured/effr/search.json": "effr.json",
+		"/secured/sofr/search.json":   "sofr.json",
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, ok := files[r.URL.Path]
+		if !ok || r.URL.Query().Get("startDate") == "" || r.URL.Query().Get("endDate") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		body, err := os.ReadFile("testdata/" + name)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write(body)
+	}))
+	t.Cleanup(srv.Close)
+	base := BaseURL
+	BaseURL = srv.URL
+	t.Cleanup(func() { BaseURL = base })
+}
+
+func day(d int) time.Time {
+	return time.Date(2022, time.May, d, 0, 0, 0, 0, time.Local)
+}
+
+func Test_FetchEFFR(t *testing.T) {
+	a := assert.New(t)
+	serveNYFed(t)
+	effr, err := Fetch(context.Background(), EFFR, day(2), day(5))
+	a.NoError(err)
+	a.Equal(SourceName, effr.Source.Source)
+	a.Contains(effr.Source.URL, "startDate=2022-05-02")
+	a.Len(effr.All(), 4)
+	rate, ok := effr.Get(day(3))
+	a.True(ok)
+	a.Equal(0.33, rate.Percent)
+	a.True(rate.HasTarget)
+	a.Equal(0.50, rate.TargetTo)
+	a.True(rate.Revised)
+
+	ranges := TargetRanges(effr)
+	a.Equal([]TargetRange{{Date: day(2), From: 0.25, To: 0.50}, {Date: day(5), From: 0.75, To: 1.00}}, ranges)
+	r, ok := RangeAt(ranges, day(4))
+	a.True(ok)
+	a.Equal(0.50, r.To)
+	r, ok = RangeAt(ranges, day(8))
+	a.True(ok)
+	a.Equal(1.00, r.To)
+	_, ok = RangeAt(ranges, day(1))
+	a.False(ok)
+}
+
+func Test_FetchSOFR(t *testing.T) {
+	a := assert.New(t)
+	serveNYFed(t)
+	sofr, err := Fetch(context.Background(), SOFR, day(2), day(6))
+	a.NoError(err)
+	rate, ok := sofr.Get(day(5))
+	a.True(ok)
+	a.Equal(0.80, rate.Percent)
+	a.False(rate.HasTarget)
+	_, ok = sofr.Get(day(6))
+	a.False(ok, "null rate")
+	a.Empty(TargetRanges(sofr))
+}
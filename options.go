@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+)
+
+const (
+	oecSheet          = "OEC"
+	fxSheet           = "Taux de change"
+	treasSheet        = "US Tresory"
+	realYieldSheet    = "US Real Yield"
+	billsSheet        = "US Bills"
+	longTermSheet     = "US Long Term"
+	realLongTermSheet = "US Real Long Term"
+	policySheet       = "US Policy Rates"
+)
+
+// allSheets are the sheets the user can choose from, in the workbook's order.
+var allSheets = []string{oecSheet, fxSheet, treasSheet, realYieldSheet, billsSheet, longTermSheet, realLongTermSheet, wsjSheet, policySheet, qualitySheet, sourcesSheet}
+
+// defaultSheets leave out the other Treasury datasets, they are only written when chosen.
+var defaultSheets = []string{oecSheet, fxSheet, treasSheet, wsjSheet, policySheet, qualitySheet, sourcesSheet}
+
+// columnSheets are the daily sheets whose columns can be chosen.
+var columnSheets = []string{oecSheet, fxSheet, treasSheet, realYieldSheet, billsSheet, longTermSheet}
+
+// sheetColumns are the columns that can be chosen for each daily sheet, in the sheet's order.
+var sheetColumns = map[string][]string{
+	oecSheet:          oecColumns,
+	fxSheet:           fxColumns,
+	treasSheet:        treasColumns,
+	realYieldSheet:    realYieldColumns,
+	billsSheet:        billColumns,
+	longTermSheet:     longTermColumns,
+	realLongTermSheet: realLongTermColumns,
+}
+
+// options are the choices made in the GUI for a run.
+type options struct {
+	// start is the first date of the daily sheets, the zero value uses each source's first date.
+	start time.Time
+	// end is the last date of the daily sheets, the zero value means today.
+	end    time.Time
+	output string
+	// template is a workbook to fill instead of writing the sheets, none when empty.
+	template string
+	// update writes the sheets in the existing output file, keeping its other sheets.
+	update bool
+	sheets []string
+	// columns are the tenors to include for each daily sheet.
+	columns map[string][]string
+	// aggregates are the titles of the frequencies of the aggregation sheets.
+	aggregates []string
+}
+
+func defaultOptions() options {
+	columns := make(map[string][]string)
+	for sheet, c := range sheetColumns {
+		columns[sheet] = c
+	}
+	// only the US dollar by default, the other currencies are there when needed
+	columns[fxSheet] = fxColumns[:1]
+	return options{
+		output:  filePath,
+		sheets:  defaultSheets,
+		columns: columns,
+	}
+}
+
+func (o options) includes(sheet string) bool {
+	return contains(o.sheets, sheet)
+}
+
+// startDate returns the first date to write for a source whose data starts at def.
+func (o options) startDate(def time.Time) time.Time {
+	if o.start.IsZero() {
+		return def
+	}
+	return o.start
+}
+
+func (o options) endDate() time.Time {
+	if o.end.IsZero() {
+		return time.Now()
+	}
+	return o.end
+}
+
+func (o options) validate() error {
+	if o.output == "" {
+		return fmt.Errorf("no output file")
+	}
+	if !o.start.IsZero() && !o.end.IsZero() && o.end.Before(o.start) {
+		return fmt.Errorf("end date %s is before start date %s", dateString(o.end), dateString(o.start))
+	}
+	if o.template != "" && o.template == o.output {
+		return fmt.Errorf("the output file must not be the template")
+	}
+	if o.template != "" && o.update {
+		return fmt.Errorf("a template can't be used to update the output file")
+	}
+	if len(o.sheets) == 0 {
+		return fmt.Errorf("no sheet selected")
+	}
+	return nil
+}
+
+// orderedSelection returns the selected values in the order of all.
+func orderedSelection(all, selected []string) []string {
+	ordered := []string{}
+	for _, v := range all {
+		if contains(selected, v) {
+			ordered = append(ordered, v)
+		}
+	}
+	return ordered
+}
+
+// selectColumns keeps the date and the selected columns of a row.
+func selectColumns(row []interface{}, columns, selected []string) []interface{} {
+	filtered := []interface{}{row[0]}
+	for i, c := range columns {
+		if i+1 < len(row) && contains(selected, c) {
+			filtered = append(filtered, row[i+1])
+		}
+	}
+	return filtered
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	prefStart      = "start"
+	prefEnd        = "end"
+	prefOutput     = "output"
+	prefTemplate   = "template"
+	prefUpdate     = "update"
+	prefSheets     = "sheets"
+	prefColumns    = "columns."
+	prefAggregates = "aggregates"
+)
+
+func loadOptions(prefs fyne.Preferences) options {
+	opts := defaultOptions()
+	opts.start = parsePrefDate(prefs.String(prefStart))
+	opts.end = parsePrefDate(prefs.String(prefEnd))
+	opts.output = prefs.StringWithFallback(prefOutput, opts.output)
+	opts.template = prefs.String(prefTemplate)
+	opts.update = prefs.Bool(prefUpdate)
+	opts.sheets = splitPref(prefs.StringWithFallback(prefSheets, strings.Join(opts.sheets, ",")))
+	opts.aggregates = splitPref(prefs.String(prefAggregates))
+	for sheet, columns := range opts.columns {
+		opts.columns[sheet] = splitPref(prefs.StringWithFallback(prefColumns+sheet, strings.Join(columns, ",")))
+	}
+	return opts
+}
+
+func saveOptions(prefs fyne.Preferences, opts options) {
+	prefs.SetString(prefStart, prefDate(opts.start))
+	prefs.SetString(prefEnd, prefDate(opts.end))
+	prefs.SetString(prefOutput, opts.output)
+	prefs.SetString(prefTemplate, opts.template)
+	prefs.SetBool(prefUpdate, opts.update)
+	prefs.SetString(prefSheets, strings.Join(opts.sheets, ","))
+	prefs.SetString(prefAggregates, strings.Join(opts.aggregates, ","))
+	for sheet, columns := range opts.columns {
+		prefs.SetString(prefColumns+sheet, strings.Join(columns, ","))
+	}
+}
+
+func splitPref(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	return strings.Split(s, ",")
+}
+
+func prefDate(d time.Time) string {
+	if d.IsZero() {
+		return ""
+	}
+	return dateString(d)
+}
+
+// parsePrefDate parses a YYYY-MM-DD date, anything else is the zero time.
+func parsePrefDate(s string) time.Time {
+	d, err := time.ParseInLocation("2006-01-02", strings.TrimSpace(s), time.Local)
+	if err != nil {
+		return time.Time{}
+	}
+	return d
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/widget"
+)
+
+// optionsForm holds the widgets used to choose the options of a run.
+type optionsForm struct {
+	start    *widget.Entry
+	end      *widget.Entry
+	output   *widget.Entry
+	template *widget.Entry
+	update   *widget.Check
+	sheets   *widget.CheckGroup
+	columns  map[string]*widget.CheckGroup
+	// aggregates are the frequencies of the aggregation sheets.
+	aggregates *widget.CheckGroup
+}
+
+func newOptionsForm(w fyne.Window, opts options) (*optionsForm, fyne.CanvasObject) {
+	f := &optionsForm{
+		start:      newDateEntry(prefDate(opts.start), "first date of each source"),
+		end:        newDateEntry(prefDate(opts.end), "today"),
+		output:     widget.NewEntry(),
+		template:   widget.NewEntry(),
+		update:     widget.NewCheck("Update the sheets of the existing file, keeping the others", nil),
+		sheets:     widget.NewCheckGroup(allSheets, nil),
+		columns:    make(map[string]*widget.CheckGroup),
+		aggregates: widget.NewCheckGroup(frequencyChoices(), nil),
+	}
+	f.output.SetText(opts.output)
+	f.template.SetPlaceHolder("none, the sheets are written in a new workbook")
+	f.template.SetText(opts.template)
+	f.update.SetChecked(opts.update)
+	f.sheets.SetSelected(opts.sheets)
+	f.sheets.Horizontal = true
+	f.aggregates.SetSelected(opts.aggregates)
+	f.aggregates.Horizontal = true
+
+	browse := widget.NewButton("Browse...", func() {
+		save := dialog.NewFileSave(func(uc fyne.URIWriteCloser, err error) {
+			if err != nil {
+				dialog.ShowError(err, w)
+				return
+			}
+			if uc == nil {
+				return
+			}
+			uc.Close()
+			f.output.SetText(uc.URI().Path())
+		}, w)
+		save.SetFileName("rates.xlsx")
+		save.SetFilter(storage.NewExtensionFileFilter([]string{".xlsx"}))
+		save.Show()
+	})
+
+	browseTemplate := widget.NewButton("Browse...", func() {
+		open := dialog.NewFileOpen(func(uc fyne.URIReadCloser, err error) {
+			if err != nil {
+				dialog.ShowError(err, w)
+				return
+			}
+			if uc == nil {
+				return
+			}
+			uc.Close()
+			f.template.SetText(uc.URI().Path())
+		}, w)
+		open.SetFilter(storage.NewExtensionFileFilter([]string{".xlsx"}))
+		open.Show()
+	})
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Start date", f.start),
+		widget.NewFormItem("End date", f.end),
+		widget.NewFormItem("Output file", container.NewBorder(nil, nil, nil, browse, f.output)),
+		widget.NewFormItem("", f.update),
+		widget.NewFormItem("Template", container.NewBorder(nil, nil, nil, browseTemplate, f.template)),
+		widget.NewFormItem("Sheets", f.sheets),
+	}
+	for _, sheet := range columnSheets {
+		group := widget.NewCheckGroup(sheetColumns[sheet], nil)
+		group.SetSelected(opts.columns[sheet])
+		group.Horizontal = true
+		f.columns[sheet] = group
+		items = append(items, widget.NewFormItem(sheet+" tenors", group))
+	}
+	items = append(items, widget.NewFormItem("Aggregations", f.aggregates))
+	return f, widget.NewForm(items...)
+}
+
+func newDateEntry(text, placeHolder string) *widget.Entry {
+	e := widget.NewEntry()
+	e.SetPlaceHolder("YYYY-MM-DD, empty for " + placeHolder)
+	e.SetText(text)
+	e.Validator = validateDate
+	return e
+}
+
+func validateDate(s string) error {
+	if strings.TrimSpace(s) != "" && parsePrefDate(s).IsZero() {
+		return fmt.Errorf("invalid date %q, expected YYYY-MM-DD", s)
+	}
+	return nil
+}
+
+// options reads the chosen options from the widgets.
+func (f *optionsForm) options() (options, error) {
+	opts := defaultOptions()
+	if err := validateDate(f.start.Text); err != nil {
+		return opts, fmt.Errorf("start date: %w", err)
+	}
+	if err := validateDate(f.end.Text); err != nil {
+		return opts, fmt.Errorf("end date: %w", err)
+	}
+	opts.start = parsePrefDate(f.start.Text)
+	opts.end = parsePrefDate(f.end.Text)
+	opts.output = strings.TrimSpace(f.output.Text)
+	opts.template = strings.TrimSpace(f.template.Text)
+	opts.update = f.update.Checked
+	opts.sheets = f.sheets.Selected
+	opts.aggregates = f.aggregates.Selected
+	for sheet, group := range f.columns {
+		opts.columns[sheet] = group.Selected
+	}
+	return opts, opts.validate()
+}
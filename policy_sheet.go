@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/clauderoy790/boc-excel-file-maker/nyfed"
+	"github.com/clauderoy790/boc-excel-file-maker/quality"
+	"github.com/xuri/excelize/v2"
+)
+
+const policyHeader = "Taux directeurs US\nSource: feuille Sources\nhttps://markets.newyorkfed.org, https://www.fedprimerate.com\n"
+
+// startDatePolicy is the first publication of SOFR.
+var startDatePolicy = time.Date(2018, 4, 2, 0, 0, 0, 0, time.Local)
+
+var policyColumns = []string{"Fed funds bas", "Fed funds haut", "EFFR", "SOFR", "Prime WSJ", "Écart prime - fed funds"}
+
+// policyQuality checks the policy rates, which the Fed moves by steps of up to 1 point, 0.75 in 2022
+// and 1 in March 2020.
+var policyQuality = policyConfig(1.5)
+
+// sofrQuality also allows the spikes of SOFR when the repo market is short of cash, 2.82 points
+// on 17 September 2019.
+var sofrQuality = policyConfig(3)
+
+func policyConfig(maxDailyChange float64) *quality.Config {
+	cfg := quality.DefaultConfig()
+	cfg.MaxDailyChange = maxDailyChange
+	return &cfg
+}
+
+// newPolicySeries returns the series of the policy sheet with the checks of the policy rates.
+func newPolicySeries() []*quality.Series {
+	series := newSheetSeries(policySheet, policyColumns, true, true)
+	for i, s := range series {
+		s.Config = policyQuality
+		if policyColumns[i] == "SOFR" {
+			s.Config = sofrQuality
+		}
+	}
+	return series
+}
+
+// writePolicySheet aligns the federal funds target range, the EFFR, SOFR and the WSJ prime by day,
+// with the spread between the prime and the top of the target range. wsj is today's prime and its history.
+func writePolicySheet(ctx context.Context, f *excelize.File, opts options, progress progressFunc, wsj wsjPrime) ([]*quality.Series, error) {
+	sheet := policySheet
+	start := opts.startDate(startDatePolicy)
+	end := opts.endDate()
+	progress(stepNYFed, 0, 2)
+	var rates []*nyfed.Rates
+	for i, kind := range []nyfed.Kind{nyfed.EFFR, nyfed.SOFR} {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		r, err := nyfed.Fetch(ctx, kind, start, end)
+		if err != nil {
+			return nil, &stepError{step: stepNYFed, detail: string(kind), err: err}
+		}
+		sources.Add(r.Source)
+		slog.Info("fetched reference rates", "source", nyfed.SourceName, "step", stepNYFed, "rate", string(kind))
+		rates = append(rates, r)
+		progress(stepNYFed, i+1, 2)
+	}
+	effr, sofr := rates[0], rates[1]
+	ranges := nyfed.TargetRanges(effr)
+	primes := primeChanges(wsj)
+
+	f.NewSheet(sheet)
+	if err := writeHeader(f, sheet, policyHeader); err != nil {
+		return nil, err
+	}
+	titles := []interface{}{tableTitle}
+	for _, c := range policyColumns {
+		titles = append(titles, c)
+	}
+	if err := setRow(f, sheet, "A5", titles); err != nil {
+		return nil, err
+	}
+	series := newPolicySeries()
+	line := 6
+	for date := start; !date.After(end); date = date.AddDate(0, 0, 1) {
+		target, hasTarget := nyfed.RangeAt(ranges, date)
+		prime, hasPrime := rateAt(primes, date)
+		values := make([]float64, len(policyColumns))
+		found := make([]bool, len(policyColumns))
+		values[0], values[1], found[0], found[1] = target.From, target.To, hasTarget, hasTarget
+		if r, ok := effr.Get(date); ok {
+			values[2], found[2] = r.Percent, true
+		}
+		if r, ok := sofr.Get(date); ok {
+			values[3], found[3] = r.Percent, true
+		}
+		values[4], found[4] = prime, hasPrime
+		values[5], found[5] = prime-target.To, hasPrime && hasTarget
+		row := treasRow(date, len(values), func(i int) (float64, bool) {
+			return values[i], found[i]
+		})
+		if err := setRow(f, sheet, fmt.Sprintf("A%d", line), row); err != nil {
+			return nil, err
+		}
+		collectRow(series, date, row)
+		line++
+	}
+	return series, nil
+}
+
+// rateChange is a rate in effect from its date, unknown when the rate from that date is not known.
+type rateChange struct {
+	date    time.Time
+	rate    float64
+	unknown bool
+}
+
+// primeChanges returns the changes of the WSJ prime rate in the history of the page, ending with today's rate.
+// When today's rate is not the last of the history, the day it changed is not known, so the prime is unknown
+// from the day after the last change of the history until today.
+func primeChanges(wsj wsjPrime) []rateChange {
+	var changes []rateChange
+	for _, h := range wsj.history {
+		changes = append(changes, rateChange{date: h.Effective, rate: h.Percent})
+	}
+	if n := len(changes); n == 0 || changes[n-1].rate != wsj.rate {
+		now := time.Now()
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+		if n > 0 {
+			if next := changes[n-1].date.AddDate(0, 0, 1); next.Before(today) {
+				changes = append(changes, rateChange{date: next, unknown: true})
+			}
+		}
+		changes = append(changes, rateChange{date: today, rate: wsj.rate})
+	}
+	return changes
+}
+
+// rateAt returns the rate in effect on date, the last change on or before it, none when it is unknown.
+func rateAt(changes []rateChange, date time.Time) (float64, bool) {
+	rate, ok := 0.0, false
+	for _, c := range changes {
+		if c.date.After(date) {
+			break
+		}
+		rate, ok = c.rate, !c.unknown
+	}
+	return rate, ok
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/clauderoy790/boc-excel-file-maker/quality"
+)
+
+// newPreview shows the sheets of a generated workbook and a chart of its series
+// so the user can check them before saving.
+func newPreview(w fyne.Window, wb *workbook, save, discard func()) fyne.CanvasObject {
+	tabs := container.NewAppTabs()
+	for _, sheet := range wb.file.GetSheetList() {
+		rows, err := wb.file.GetRows(sheet)
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("error reading sheet %s: %w", sheet, err), w)
+			continue
+		}
+		tabs.Append(container.NewTabItem(sheet, newSheetTable(rows)))
+	}
+	tabs.Append(container.NewTabItem("Chart", newSeriesChart(wb.series)))
+
+	buttons := container.NewHBox(
+		widget.NewButton("Save", save),
+		widget.NewButton("Discard", discard),
+	)
+	return container.NewBorder(nil, buttons, nil, nil, tabs)
+}
+
+func newSheetTable(rows [][]string) *widget.Table {
+	cols := 0
+	for _, r := range rows {
+		if len(r) > cols {
+			cols = len(r)
+		}
+	}
+	table := widget.NewTable(
+		func() (int, int) {
+			return len(rows), cols
+		},
+		func() fyne.CanvasObject {
+			return widget.NewLabel("0000-00-00 0000")
+		},
+		func(id widget.TableCellID, o fyne.CanvasObject) {
+			text := ""
+			if id.Col < len(rows[id.Row]) {
+				text = rows[id.Row][id.Col]
+			}
+			o.(*widget.Label).SetText(text)
+		},
+	)
+	return table
+}
+
+// newSeriesChart shows a line chart of the series checked in the list next to it.
+func newSeriesChart(series []*quality.Series) fyne.CanvasObject {
+	chart := newLineChart()
+	byName := make(map[string]*quality.Series)
+	var names []string
+	for _, s := range series {
+		name := s.Sheet + " " + s.Name
+		byName[name] = s
+		names = append(names, name)
+	}
+	checks := widget.NewCheckGroup(names, func(selected []string) {
+		var shown []*quality.Series
+		for _, name := range names {
+			if contains(selected, name) {
+				shown = append(shown, byName[name])
+			}
+		}
+		chart.setSeries(shown)
+	})
+	return container.NewHSplit(container.NewVScroll(checks), chart)
+}
@@ -0,0 +1,54 @@
+// Package prime tells which prime rate most of the major Canadian banks agree on.
+package prime
+
+import (
+	"math"
+	"sort"
+)
+
+// Consensus is the rate most banks agree on.
+type Consensus struct {
+	Rate float64
+	// Count is the number of banks with the rate, out of Total banks with a rate.
+	Count int
+	Total int
+}
+
+// NewConsensus returns the most common of the rates, by bank. Rates tied for the most
+// common give the lowest one. It has none when there are no rates.
+func NewConsensus(rates map[string]float64) (Consensus, bool) {
+	counts := make(map[float64]int)
+	for _, r := range rates {
+		counts[round(r)]++
+	}
+	if len(counts) == 0 {
+		return Consensus{}, false
+	}
+	values := make([]float64, 0, len(counts))
+	for v := range counts {
+		values = append(values, v)
+	}
+	sort.Float64s(values)
+	c := Consensus{Total: len(rates)}
+	for _, v := range values {
+		if counts[v] > c.Count {
+			c.Rate, c.Count = v, counts[v]
+		}
+	}
+	return c, true
+}
+
+// Majority tells whether more than half of the banks have the rate.
+func (c Consensus) Majority() bool {
+	return c.Count*2 > c.Total
+}
+
+// Diverges tells whether a rate differs from the consensus.
+func (c Consensus) Diverges(rate float64) bool {
+	return round(rate) != c.Rate
+}
+
+// round keeps 2 decimals so rates parsed differently compare equal.
+func round(v float64) float64 {
+	return math.Round(v*100) / 100
+}
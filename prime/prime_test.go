@@ -0,0 +1,25 @@
+package prime
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_NewConsensus(t *testing.T) {
+	a := assert.New(t)
+	c, ok := NewConsensus(map[string]float64{"RBC": 6.7, "TD": 6.70, "BMO": 6.7, "CIBC": 6.95, "Scotiabank": 6.7})
+	a.True(ok)
+	a.Equal(Consensus{Rate: 6.7, Count: 4, Total: 5}, c)
+	a.True(c.Majority())
+	a.True(c.Diverges(6.95))
+	a.False(c.Diverges(6.7))
+
+	c, ok = NewConsensus(map[string]float64{"RBC": 6.95, "TD": 6.7})
+	a.True(ok)
+	a.Equal(6.7, c.Rate, "ties give the lowest rate")
+	a.False(c.Majority())
+
+	_, ok = NewConsensus(nil)
+	a.False(ok)
+}
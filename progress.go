@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+	"github.com/clauderoy790/boc-excel-file-maker/scrape"
+)
+
+// steps of a run, in the order they happen
+const (
+	stepValet        = "Bank of Canada Valet"
+	stepBoC          = "Bank of Canada"
+	stepFX           = "Bank of Canada exchange rates"
+	stepTreasury     = "US Treasury"
+	stepRealYield    = "US Treasury real yields"
+	stepBills        = "US Treasury bills"
+	stepLongTerm     = "US Treasury long-term rates"
+	stepRealLongTerm = "US Treasury real long-term rates"
+	stepWSJ          = "Wall Street Journal"
+	stepBNC          = "Banque Nationale"
+	stepPrimes       = "Canadian banks"
+	stepScrapers     = "Other rates"
+	stepNYFed        = "New York Fed"
+	stepQuality      = "Quality checks"
+	stepWriting      = "Writing file"
+)
+
+// progressFunc is called when a step of a run progresses.
+type progressFunc func(step string, done, total int)
+
+// runSteps returns the steps a run with these options goes through.
+func runSteps(opts options) []string {
+	var steps []string
+	if len(activeValetSheets(opts)) > 0 {
+		steps = append(steps, stepValet)
+	}
+	if opts.includes(oecSheet) {
+		steps = append(steps, stepBoC)
+	}
+	if opts.includes(fxSheet) {
+		steps = append(steps, stepFX)
+	}
+	for _, ds := range treasDatasets {
+		if opts.includes(ds.sheet) {
+			steps = append(steps, ds.step)
+		}
+	}
+	if opts.includes(wsjSheet) || opts.includes(policySheet) {
+		steps = append(steps, stepWSJ)
+	}
+	if opts.includes(wsjSheet) {
+		steps = append(steps, stepBNC, stepPrimes)
+		if len(scrape.InGroup(scrapers, scrape.GroupOther)) > 0 {
+			steps = append(steps, stepScrapers)
+		}
+	}
+	if opts.includes(policySheet) {
+		steps = append(steps, stepNYFed)
+	}
+	return append(steps, stepQuality, stepWriting)
+}
+
+// stepError tells which step of a run failed.
+type stepError struct {
+	step   string
+	detail string
+	err    error
+}
+
+func (e *stepError) Error() string {
+	if e.detail == "" {
+		return fmt.Sprintf("%s: %v", e.step, e.err)
+	}
+	return fmt.Sprintf("%s (%s): %v", e.step, e.detail, e.err)
+}
+
+func (e *stepError) Unwrap() error {
+	return e.err
+}
+
+// progressView shows a progress bar for each step of a run and a cancel button.
+type progressView struct {
+	labels  map[string]*widget.Label
+	bars    map[string]*widget.ProgressBar
+	content fyne.CanvasObject
+}
+
+func newProgressView(steps []string, cancel func()) *progressView {
+	p := &progressView{
+		labels: make(map[string]*widget.Label),
+		bars:   make(map[string]*widget.ProgressBar),
+	}
+	box := container.NewVBox()
+	for _, step := range steps {
+		p.labels[step] = widget.NewLabel(step)
+		p.bars[step] = widget.NewProgressBar()
+		box.Add(p.labels[step])
+		box.Add(p.bars[step])
+	}
+	var cancelBtn *widget.Button
+	cancelBtn = widget.NewButton("Cancel", func() {
+		cancelBtn.Disable()
+		cancelBtn.SetText("Cancelling...")
+		cancel()
+	})
+	box.Add(cancelBtn)
+	p.content = box
+	return p
+}
+
+func (p *progressView) update(step string, done, total int) {
+	bar, ok := p.bars[step]
+	if !ok || total == 0 {
+		return
+	}
+	bar.Max = float64(total)
+	bar.SetValue(float64(done))
+	if total > 1 {
+		p.labels[step].SetText(fmt.Sprintf("%s: %d of %d", step, done, total))
+	}
+}
@@ -0,0 +1,61 @@
+package provenance
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// Cache tells whether a record was served from the local cache.
+type Cache string
+
+const (
+	NoCache   Cache = ""
+	CacheHit  Cache = "hit"
+	CacheMiss Cache = "miss"
+)
+
+// Record describes where one fetched unit of data (a Treasury month, a web page...) came from.
+type Record struct {
+	Source    string
+	Unit      string
+	URL       string
+	FetchedAt time.Time
+	// Status is the HTTP status code, 0 when the data did not come from a request made during this run.
+	Status int
+	Cache  Cache
+	Hash   string
+	Note   string
+}
+
+// Log collects the records of a run, it is safe for concurrent use.
+type Log struct {
+	mu      sync.Mutex
+	records []Record
+}
+
+func NewLog() *Log {
+	return &Log{}
+}
+
+func (l *Log) Add(r Record) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.records = append(l.records, r)
+}
+
+// Records returns a copy of the records in the order they were added.
+func (l *Log) Records() []Record {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	records := make([]Record, len(l.records))
+	copy(records, l.records)
+	return records
+}
+
+// Hash returns the hex encoded SHA-256 of the content.
+func Hash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,34 @@
+package provenance
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Log(t *testing.T) {
+	a := assert.New(t)
+	l := NewLog()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.Add(Record{Source: "BoC"})
+		}()
+	}
+	wg.Wait()
+	records := l.Records()
+	a.Len(records, 10)
+
+	records[0].Source = "changed"
+	a.Equal("BoC", l.Records()[0].Source)
+}
+
+func Test_Hash(t *testing.T) {
+	a := assert.New(t)
+	a.Equal("e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", Hash(nil))
+	a.Equal(Hash([]byte("rates")), Hash([]byte("rates")))
+	a.NotEqual(Hash([]byte("rates")), Hash([]byte("rate")))
+}
@@ -0,0 +1,206 @@
+package quality
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Severity tells how serious a finding is. Only errors fail a strict run.
+type Severity int
+
+const (
+	Warning Severity = iota
+	Error
+)
+
+func (s Severity) String() string {
+	if s == Error {
+		return "error"
+	}
+	return "warning"
+}
+
+// Kind is the name of the check that produced a finding.
+type Kind string
+
+const (
+	Jump    Kind = "jump"
+	Bounds  Kind = "bounds"
+	Stale   Kind = "stale"
+	Missing Kind = "missing"
+)
+
+type Observation struct {
+	Date  time.Time
+	Value float64
+}
+
+// Series is a list of observations, in percent, for one column of a sheet.
+// Set Daily for series that are expected to have a value every business day.
+type Series struct {
+	Sheet string
+	Name  string
+	Daily bool
+	// Percent tells that the values are rates in percent, which the sheets show as fractions.
+	Percent bool
+	// Config replaces the configuration given to Check for a series that is not a rate, except for AsOf.
+	Config       *Config
+	Observations []Observation
+}
+
+func (s *Series) Add(date time.Time, value float64) {
+	s.Observations = append(s.Observations, Observation{Date: date, Value: value})
+}
+
+type Finding struct {
+	Severity Severity
+	Kind     Kind
+	Sheet    string
+	Series   string
+	Date     time.Time
+	Message  string
+}
+
+type Config struct {
+	// MaxDailyChange is the largest move, in percentage points, allowed between two consecutive observations.
+	MaxDailyChange float64
+	MinValue       float64
+	MaxValue       float64
+	// StaleAfter is how old the last observation of a daily series can be.
+	StaleAfter time.Duration
+	// AsOf is the date the run is made for, usually today.
+	AsOf time.Time
+}
+
+func DefaultConfig() Config {
+	return Config{
+		MaxDailyChange: 0.5,
+		MinValue:       -1,
+		MaxValue:       25,
+		StaleAfter:     7 * 24 * time.Hour,
+		AsOf:           time.Now(),
+	}
+}
+
+// Check runs every check on the series and returns the findings ordered by sheet, series and date.
+func Check(cfg Config, series []*Series) []Finding {
+	var findings []Finding
+	for _, s := range series {
+		c := cfg
+		if s.Config != nil {
+			c = *s.Config
+			c.AsOf = cfg.AsOf
+		}
+		obs := sortedObservations(s)
+		findings = append(findings, checkBounds(c, s, obs)...)
+		findings = append(findings, checkJumps(c, s, obs)...)
+		if s.Daily {
+			findings = append(findings, checkStale(c, s, obs)...)
+			findings = append(findings, checkMissing(s, obs)...)
+		}
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if a.Sheet != b.Sheet {
+			return a.Sheet < b.Sheet
+		}
+		if a.Series != b.Series {
+			return a.Series < b.Series
+		}
+		return a.Date.Before(b.Date)
+	})
+	return findings
+}
+
+// Errors returns the findings with an error severity.
+func Errors(findings []Finding) []Finding {
+	var errs []Finding
+	for _, f := range findings {
+		if f.Severity == Error {
+			errs = append(errs, f)
+		}
+	}
+	return errs
+}
+
+func sortedObservations(s *Series) []Observation {
+	obs := make([]Observation, len(s.Observations))
+	copy(obs, s.Observations)
+	sort.SliceStable(obs, func(i, j int) bool {
+		return obs[i].Date.Before(obs[j].Date)
+	})
+	return obs
+}
+
+func checkBounds(cfg Config, s *Series, obs []Observation) []Finding {
+	var findings []Finding
+	for _, o := range obs {
+		if o.Value < cfg.MinValue || o.Value > cfg.MaxValue {
+			findings = append(findings, newFinding(Error, Bounds, s, o.Date,
+				fmt.Sprintf("value %.2f is outside of [%.2f, %.2f]", o.Value, cfg.MinValue, cfg.MaxValue)))
+		}
+	}
+	return findings
+}
+
+func checkJumps(cfg Config, s *Series, obs []Observation) []Finding {
+	var findings []Finding
+	for i := 1; i < len(obs); i++ {
+		diff := obs[i].Value - obs[i-1].Value
+		if math.Abs(diff) > cfg.MaxDailyChange {
+			findings = append(findings, newFinding(Error, Jump, s, obs[i].Date,
+				fmt.Sprintf("moved %+.2f since %s (%.2f to %.2f)", diff, dateString(obs[i-1].Date), obs[i-1].Value, obs[i].Value)))
+		}
+	}
+	return findings
+}
+
+func checkStale(cfg Config, s *Series, obs []Observation) []Finding {
+	if len(obs) == 0 {
+		return []Finding{newFinding(Error, Stale, s, cfg.AsOf, "series has no observations")}
+	}
+	last := obs[len(obs)-1].Date
+	if cfg.AsOf.Sub(last) > cfg.StaleAfter {
+		return []Finding{newFinding(Error, Stale, s, last,
+			fmt.Sprintf("last observation is from %s, %d days before %s", dateString(last), int(cfg.AsOf.Sub(last).Hours()/24), dateString(cfg.AsOf)))}
+	}
+	return nil
+}
+
+// checkMissing reports the business days between the first and last observation that have no data.
+// Holidays show up here too, which is why they are only warnings.
+func checkMissing(s *Series, obs []Observation) []Finding {
+	if len(obs) < 2 {
+		return nil
+	}
+	seen := make(map[string]bool, len(obs))
+	for _, o := range obs {
+		seen[dateString(o.Date)] = true
+	}
+	var findings []Finding
+	last := obs[len(obs)-1].Date
+	for d := obs[0].Date; d.Before(last); d = d.AddDate(0, 0, 1) {
+		if d.Weekday() == time.Saturday || d.Weekday() == time.Sunday || seen[dateString(d)] {
+			continue
+		}
+		findings = append(findings, newFinding(Warning, Missing, s, d, "no data for this business day"))
+	}
+	return findings
+}
+
+func newFinding(sev Severity, kind Kind, s *Series, date time.Time, msg string) Finding {
+	return Finding{
+		Severity: sev,
+		Kind:     kind,
+		Sheet:    s.Sheet,
+		Series:   s.Name,
+		Date:     date,
+		Message:  msg,
+	}
+}
+
+func dateString(dt time.Time) string {
+	return fmt.Sprintf("%04d-%02d-%02d", dt.Year(), int(dt.Month()), dt.Day())
+}
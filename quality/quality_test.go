@@ -0,0 +1,91 @@
+package quality
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func day(d int) time.Time {
+	// 2022-05-02 is a monday
+	return time.Date(2022, 5, d, 0, 0, 0, 0, time.Local)
+}
+
+func newTestConfig() Config {
+	cfg := DefaultConfig()
+	cfg.AsOf = day(9)
+	return cfg
+}
+
+func Test_CheckClean(t *testing.T) {
+	a := assert.New(t)
+	s := &Series{Sheet: "OEC", Name: "5 ans", Daily: true}
+	for d := 2; d <= 6; d++ {
+		s.Add(day(d), 2.5+float64(d)/100)
+	}
+	a.Empty(Check(newTestConfig(), []*Series{s}))
+}
+
+func Test_CheckJumpAndBounds(t *testing.T) {
+	a := assert.New(t)
+	s := &Series{Sheet: "US Tresory", Name: "2 ans"}
+	s.Add(day(2), 2.7)
+	s.Add(day(3), 0)
+	s.Add(day(4), 30)
+
+	findings := Check(newTestConfig(), []*Series{s})
+	a.Len(findings, 3)
+	a.Equal(Jump, findings[0].Kind)
+	a.Equal(day(3), findings[0].Date)
+	a.Equal(Bounds, findings[1].Kind)
+	a.Equal(day(4), findings[1].Date)
+	a.Equal(Jump, findings[2].Kind)
+	a.Len(Errors(findings), 3)
+}
+
+func Test_CheckStale(t *testing.T) {
+	a := assert.New(t)
+	s := &Series{Sheet: "OEC", Name: "5 ans", Daily: true}
+	s.Add(day(2), 2.5)
+	cfg := newTestConfig()
+	cfg.AsOf = day(20)
+
+	findings := Check(cfg, []*Series{s})
+	a.Len(findings, 1)
+	a.Equal(Stale, findings[0].Kind)
+	a.Equal(Error, findings[0].Severity)
+
+	findings = Check(cfg, []*Series{{Sheet: "OEC", Name: "empty", Daily: true}})
+	a.Len(findings, 1)
+	a.Equal(Stale, findings[0].Kind)
+}
+
+func Test_CheckMissing(t *testing.T) {
+	a := assert.New(t)
+	s := &Series{Sheet: "OEC", Name: "5 ans", Daily: true}
+	s.Add(day(6), 2.5)
+	s.Add(day(2), 2.5)
+	s.Add(day(9), 2.5)
+
+	findings := Check(newTestConfig(), []*Series{s})
+	a.Len(findings, 3)
+	for i, d := range []int{3, 4, 5} {
+		a.Equal(Missing, findings[i].Kind)
+		a.Equal(Warning, findings[i].Severity)
+		a.Equal(day(d), findings[i].Date)
+	}
+	a.Empty(Errors(findings))
+}
+
+func Test_CheckSeriesConfig(t *testing.T) {
+	a := assert.New(t)
+	s := &Series{Sheet: "Taux de change", Name: "USD/CAD", Config: &Config{MaxDailyChange: 0.05, MinValue: 0, MaxValue: 10}}
+	s.Add(day(2), 1.28)
+	s.Add(day(3), 1.29)
+	s.Add(day(4), 1.39)
+	findings := Check(newTestConfig(), []*Series{s})
+	a.Len(findings, 1)
+	a.Equal(Jump, findings[0].Kind)
+	a.Equal(day(4), findings[0].Date)
+}
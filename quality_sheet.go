@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/clauderoy790/boc-excel-file-maker/quality"
+	"github.com/xuri/excelize/v2"
+)
+
+const qualitySheet = "Quality"
+
+func newSheetSeries(sheet string, columns []string, daily, percent bool) []*quality.Series {
+	series := make([]*quality.Series, len(columns))
+	for i, c := range columns {
+		series[i] = &quality.Series{Sheet: sheet, Name: c, Daily: daily, Percent: percent}
+	}
+	return series
+}
+
+// qualityConfig returns the checks of a run, the series being stale as of its end date.
+func qualityConfig(opts options) quality.Config {
+	cfg := quality.DefaultConfig()
+	cfg.AsOf = opts.endDate()
+	return cfg
+}
+
+// collectRow adds the values of a sheet row to its series. The first cell is the date and
+// the others are the formatted rates, "n/a" cells are skipped.
+func collectRow(series []*quality.Series, date time.Time, row []interface{}) {
+	for i, s := range series {
+		if i+1 >= len(row) {
+			return
+		}
+		str, ok := row[i+1].(string)
+		if !ok {
+			continue
+		}
+		v, err := strconv.ParseFloat(str, 64)
+		if err != nil {
+			continue
+		}
+		s.Add(date, v*100)
+	}
+}
+
+func writeQualitySheet(f *excelize.File, findings []quality.Finding) error {
+	sheet := qualitySheet
+	f.NewSheet(sheet)
+
+	errs := quality.Errors(findings)
+	if err := f.SetCellValue(sheet, "A1", "Data quality checks"); err != nil {
+		return fmt.Errorf("error setting sheet value: %w", err)
+	}
+	summary := fmt.Sprintf("%d errors, %d warnings", len(errs), len(findings)-len(errs))
+	if len(findings) == 0 {
+		summary = "No issues found"
+	}
+	if err := f.SetCellValue(sheet, "A2", summary); err != nil {
+		return fmt.Errorf("error setting sheet value: %w", err)
+	}
+	if err := f.SetSheetRow(sheet, "A4", &[]interface{}{"Severity", "Check", "Sheet", "Series", "Date", "Message"}); err != nil {
+		return fmt.Errorf("error setting header: %w", err)
+	}
+	for i, finding := range findings {
+		row := []interface{}{finding.Severity.String(), string(finding.Kind), finding.Sheet, finding.Series, colDateString(finding.Date), finding.Message}
+		if err := f.SetSheetRow(sheet, fmt.Sprintf("A%d", i+5), &row); err != nil {
+			return fmt.Errorf("error setting finding row: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,425 @@
+// Package rates looks up the rates of the workbook from Go: the Bank of Canada bond yields of
+// the OEC sheet, with its derived 4 year yield, and the US Treasury yield curve.
+//
+// Rates are in percent. A day without an observation, a week-end, a holiday or a day not
+// published yet, is missing: RateAt and Curve return an error wrapping ErrNoObservation
+// and Series leaves the day out. No value is ever carried over from another day.
+package rates
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	boc "github.com/clauderoy790/bank-of-canada-interests-rates"
+	"github.com/clauderoy790/boc-excel-file-maker/common"
+	"github.com/clauderoy790/boc-excel-file-maker/fetch"
+	"github.com/clauderoy790/boc-excel-file-maker/provenance"
+	"github.com/clauderoy790/boc-excel-file-maker/treasury"
+)
+
+// Source is where rates come from.
+type Source string
+
+const (
+	// OEC are the Government of Canada benchmark bond yields of the Bank of Canada.
+	OEC Source = "oec"
+	// Treasury is the US Treasury par yield curve.
+	Treasury Source = "ust"
+)
+
+// BoCURL is the Bank of Canada group the OEC yields are read from.
+const BoCURL = "https://www.banqueducanada.ca/valet/observations/group/bond_yields_all/json"
+
+// BoCSourceName is the name of the Bank of Canada in provenance records.
+const BoCSourceName = "Bank of Canada"
+
+// Tenor is the maturity of a rate, like 5y.
+type Tenor string
+
+// tenors of both sources
+const (
+	Month1  Tenor = "1m"
+	Month2  Tenor = "2m"
+	Month3  Tenor = "3m"
+	Month4  Tenor = "4m"
+	Month6  Tenor = "6m"
+	Year1   Tenor = "1y"
+	Year2   Tenor = "2y"
+	Year3   Tenor = "3y"
+	Year4   Tenor = "4y"
+	Year5   Tenor = "5y"
+	Year6   Tenor = "6y"
+	Year7   Tenor = "7y"
+	Year8   Tenor = "8y"
+	Year10  Tenor = "10y"
+	Year20  Tenor = "20y"
+	Year30  Tenor = "30y"
+	Long    Tenor = "long"
+	Avg1To3 Tenor = "1-3y"
+	Avg3To5 Tenor = "3-5y"
+	// RealReturn is the yield of the real return bonds.
+	RealReturn Tenor = "rrb"
+)
+
+// ErrNoObservation is wrapped by the errors of the days without a rate.
+var ErrNoObservation = errors.New("no observation")
+
+// Rate is the rate of a tenor on a day.
+type Rate struct {
+	Date  time.Time
+	Tenor Tenor
+	Value float64
+}
+
+// YieldCurve is the rates of a source on a day, by tenor. A tenor not published that day is not in it.
+type YieldCurve struct {
+	Source Source
+	Date   time.Time
+	Rates  map[Tenor]float64
+	// Invalid are the tenors published that day with a value that is not a number, left out of Rates.
+	Invalid map[Tenor]*InvalidValueError
+	// Provenance tells where the data of the day came from, zero when the source records none.
+	Provenance provenance.Record
+}
+
+// Tenors returns the tenors of the source, from the shortest.
+func Tenors(source Source) []Tenor {
+	switch source {
+	case OEC:
+		return []Tenor{Avg1To3, Year2, Year3, Avg3To5, Year4, Year5, Year7, Year10, Long, RealReturn}
+	case Treasury:
+		return []Tenor{Month1, Month2, Month3, Month4, Month6, Year1, Year2, Year3, Year4, Year5, Year6, Year7, Year8, Year10, Year20, Year30}
+	}
+	return nil
+}
+
+// InvalidValueError is a value published by a source that is not a number.
+type InvalidValueError struct {
+	Source Source
+	Tenor  Tenor
+	Date   time.Time
+	Value  string
+	Err    error
+}
+
+func (e *InvalidValueError) Error() string {
+	return fmt.Sprintf("%s %s %s: invalid value %q: %v", e.Source, e.Tenor, e.Date.Format("2006-01-02"), e.Value, e.Err)
+}
+
+func (e *InvalidValueError) Unwrap() error {
+	return e.Err
+}
+
+// Client fetches the data of the sources once and keeps it for its lookups. Use a new
+// client to see newly published rates.
+type Client struct {
+	mu         sync.Mutex
+	bank       boc.BOCInterests
+	bankSource provenance.Record
+	// months of the Treasury curve, by first day
+	months map[string]treasuryMonth
+
+	// fetchBank and fetchMonth are replaced by the tests.
+	fetchBank  func(ctx context.Context) (boc.BOCInterests, provenance.Record, error)
+	fetchMonth func(ctx context.Context, month time.Time) (dayRecords, provenance.Record, error)
+}
+
+// dayRecords are the Treasury records of a month.
+type dayRecords interface {
+	GetRecordForDate(date string) (*treasury.Record, error)
+}
+
+// treasuryMonth is a month of the Treasury curve and where it came from.
+type treasuryMonth struct {
+	records dayRecords
+	source  provenance.Record
+}
+
+// NewClient returns a client that has fetched nothing yet.
+func NewClient() *Client {
+	return &Client{
+		months: make(map[string]treasuryMonth),
+		fetchBank: func(ctx context.Context) (boc.BOCInterests, provenance.Record, error) {
+			return fetchBank(ctx, BoCURL)
+		},
+		fetchMonth: func(ctx context.Context, month time.Time) (dayRecords, provenance.Record, error) {
+			t, err := treasury.FetchData(ctx, month)
+			if err != nil {
+				return nil, provenance.Record{}, err
+			}
+			return t, t.Source, nil
+		},
+	}
+}
+
+// Default is the client of the package functions.
+var Default = NewClient()
+
+// RateAt returns the rate of a tenor on a day with Default.
+func RateAt(ctx context.Context, source Source, tenor Tenor, date time.Time) (float64, error) {
+	return Default.RateAt(ctx, source, tenor, date)
+}
+
+// Series returns the rates of a tenor from one day to another with Default.
+func Series(ctx context.Context, source Source, tenor Tenor, from, to time.Time) ([]Rate, error) {
+	return Default.Series(ctx, source, tenor, from, to)
+}
+
+// Curve returns the curve of a source on a day with Default.
+func Curve(ctx context.Context, source Source, date time.Time) (*YieldCurve, error) {
+	return Default.Curve(ctx, source, date)
+}
+
+// RateAt returns the rate of a tenor on a day, an error wrapping ErrNoObservation when
+// the source has none that day and an *InvalidValueError when its value is not a number.
+func (c *Client) RateAt(ctx context.Context, source Source, tenor Tenor, date time.Time) (float64, error) {
+	if !validTenor(source, tenor) {
+		return 0, fmt.Errorf("%s has no %s tenor", source, tenor)
+	}
+	curve, err := c.Curve(ctx, source, date)
+	if err != nil {
+		return 0, err
+	}
+	if invalid, ok := curve.Invalid[tenor]; ok {
+		return 0, invalid
+	}
+	v, ok := curve.Rates[tenor]
+	if !ok {
+		return 0, fmt.Errorf("%s %s %s: %w", source, tenor, day(date), ErrNoObservation)
+	}
+	return v, nil
+}
+
+// Series returns the rates of a tenor of each day from from to to included that has one.
+func (c *Client) Series(ctx context.Context, source Source, tenor Tenor, from, to time.Time) ([]Rate, error) {
+	if !validTenor(source, tenor) {
+		return nil, fmt.Errorf("%s has no %s tenor", source, tenor)
+	}
+	var series []Rate
+	for d := truncate(from); !d.After(to); d = d.AddDate(0, 0, 1) {
+		curve, err := c.Curve(ctx, source, d)
+		if errors.Is(err, ErrNoObservation) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if invalid, ok := curve.Invalid[tenor]; ok {
+			return nil, invalid
+		}
+		if v, ok := curve.Rates[tenor]; ok {
+			series = append(series, Rate{Date: d, Tenor: tenor, Value: v})
+		}
+	}
+	return series, nil
+}
+
+// Curve returns the rates of a source on a day, an error wrapping ErrNoObservation when
+// it published none that day. A tenor whose value is not a number is in Invalid, so it
+// does not keep the others from being read.
+func (c *Client) Curve(ctx context.Context, source Source, date time.Time) (*YieldCurve, error) {
+	date = truncate(date)
+	switch source {
+	case OEC:
+		return c.oecCurve(ctx, date)
+	case Treasury:
+		return c.treasuryCurve(ctx, date)
+	}
+	return nil, fmt.Errorf("unknown source %q", source)
+}
+
+// Provenance returns where the data of a source on a day comes from, fetching it if needed:
+// the month of the day for the Treasury, every day at once for the Bank of Canada.
+func (c *Client) Provenance(ctx context.Context, source Source, date time.Time) (provenance.Record, error) {
+	switch source {
+	case OEC:
+		if _, err := c.BankOfCanada(ctx); err != nil {
+			return provenance.Record{}, err
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.bankSource, nil
+	case Treasury:
+		month, err := c.treasuryMonth(ctx, truncate(date))
+		return month.source, err
+	}
+	return provenance.Record{}, fmt.Errorf("unknown source %q", source)
+}
+
+// BankOfCanada returns the Bank of Canada bond yields, fetching them on the first call.
+func (c *Client) BankOfCanada(ctx context.Context) (boc.BOCInterests, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.bank != nil {
+		return c.bank, nil
+	}
+	bank, src, err := c.fetchBank(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.bank, c.bankSource = bank, src
+	return bank, nil
+}
+
+// fetchBank fetches the bond yields of the Bank of Canada at url and tells where they came from.
+func fetchBank(ctx context.Context, url string) (boc.BOCInterests, provenance.Record, error) {
+	src := provenance.Record{Source: BoCSourceName, Unit: "bond_yields_all", URL: url}
+	resp, err := fetch.Get(ctx, url)
+	if resp != nil {
+		src.FetchedAt = resp.FetchedAt
+		src.Status = resp.Status
+	}
+	if err != nil {
+		return nil, src, err
+	}
+	src.Hash = provenance.Hash(resp.Body)
+	var data boc.BOCData
+	if err := json.Unmarshal(resp.Body, &data); err != nil {
+		return nil, src, &fetch.SourceError{URL: url, Status: resp.Status, Err: fmt.Errorf("error reading bond yields: %w", err)}
+	}
+	bank := &bankData{data: data, observations: make(map[string]*boc.Observations)}
+	for i := range data.Observations {
+		bank.observations[data.Observations[i].D] = &data.Observations[i]
+	}
+	return bank, src, nil
+}
+
+// bankData is the bond yields read from a response, as the boc library reads them.
+type bankData struct {
+	data         boc.BOCData
+	observations map[string]*boc.Observations
+}
+
+func (b *bankData) GetObservationForDate(date string) (*boc.Observations, error) {
+	d, err := boc.FormatDate(date)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date format: %s", date)
+	}
+	obs, ok := b.observations[d]
+	if !ok {
+		return nil, fmt.Errorf("no data for this date: %s", d)
+	}
+	return obs, nil
+}
+
+func (b *bankData) GroupDetail() boc.GroupDetail   { return b.data.GroupDetail }
+func (b *bankData) Terms() boc.Terms               { return b.data.Terms }
+func (b *bankData) SeriesDetail() boc.SeriesDetail { return b.data.SeriesDetail }
+
+func (c *Client) oecCurve(ctx context.Context, date time.Time) (*YieldCurve, error) {
+	bank, err := c.BankOfCanada(ctx)
+	if err != nil {
+		return nil, err
+	}
+	obs, err := bank.GetObservationForDate(day(date))
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", OEC, day(date), ErrNoObservation)
+	}
+	c.mu.Lock()
+	src := c.bankSource
+	c.mu.Unlock()
+	curve := &YieldCurve{Source: OEC, Date: date, Rates: make(map[Tenor]float64), Invalid: make(map[Tenor]*InvalidValueError), Provenance: src}
+	for tenor, v := range map[Tenor]boc.Val{
+		Avg1To3:    obs.Average1To3Year,
+		Year2:      obs.Yield2Year,
+		Year3:      obs.Yield3Year,
+		Avg3To5:    obs.Average3To5Year,
+		Year5:      obs.Yield5Year,
+		Year7:      obs.Yield7Year,
+		Year10:     obs.Yield10Year,
+		Long:       obs.YieldLong,
+		RealReturn: obs.YieldRRB,
+	} {
+		if v.V == "" {
+			continue
+		}
+		f, err := strconv.ParseFloat(v.V, 64)
+		if err != nil {
+			curve.Invalid[tenor] = &InvalidValueError{Source: OEC, Tenor: tenor, Date: date, Value: v.V, Err: err}
+			continue
+		}
+		curve.Rates[tenor] = f
+	}
+	// the Bank of Canada publishes no 4 year yield, it is the average of the 3 and 5 year yields
+	three, ok3 := curve.Rates[Year3]
+	five, ok5 := curve.Rates[Year5]
+	if ok3 && ok5 {
+		curve.Rates[Year4] = common.Average(three, five)
+	}
+	for _, t := range []Tenor{Year3, Year5} {
+		if invalid, ok := curve.Invalid[t]; ok {
+			curve.Invalid[Year4] = invalid
+		}
+	}
+	if len(curve.Rates) == 0 && len(curve.Invalid) == 0 {
+		return nil, fmt.Errorf("%s %s: %w", OEC, day(date), ErrNoObservation)
+	}
+	return curve, nil
+}
+
+var treasuryTenors = map[Tenor]treasury.Tenor{
+	Month1: treasury.Month1, Month2: treasury.Month2, Month3: treasury.Month3, Month4: treasury.Month4, Month6: treasury.Month6,
+	Year1: treasury.Year1, Year2: treasury.Year2, Year3: treasury.Year3, Year4: treasury.Year4, Year5: treasury.Year5,
+	Year6: treasury.Year6, Year7: treasury.Year7, Year8: treasury.Year8, Year10: treasury.Year10, Year20: treasury.Year20,
+	Year30: treasury.Year30,
+}
+
+// treasuryMonth returns the month of the Treasury curve of date, fetching it on the first call.
+func (c *Client) treasuryMonth(ctx context.Context, date time.Time) (treasuryMonth, error) {
+	month := time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, date.Location())
+	c.mu.Lock()
+	m, ok := c.months[day(month)]
+	c.mu.Unlock()
+	if ok {
+		return m, nil
+	}
+	records, source, err := c.fetchMonth(ctx, month)
+	if err != nil {
+		return m, err
+	}
+	m = treasuryMonth{records: records, source: source}
+	c.mu.Lock()
+	c.months[day(month)] = m
+	c.mu.Unlock()
+	return m, nil
+}
+
+func (c *Client) treasuryCurve(ctx context.Context, date time.Time) (*YieldCurve, error) {
+	month, err := c.treasuryMonth(ctx, date)
+	if err != nil {
+		return nil, err
+	}
+	r, err := month.records.GetRecordForDate(day(date))
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", Treasury, day(date), ErrNoObservation)
+	}
+	curve := &YieldCurve{Source: Treasury, Date: date, Rates: make(map[Tenor]float64), Provenance: month.source}
+	for tenor, t := range treasuryTenors {
+		if v, ok := r.Value(t); ok {
+			curve.Rates[tenor] = v
+		}
+	}
+	return curve, nil
+}
+
+func validTenor(source Source, tenor Tenor) bool {
+	for _, t := range Tenors(source) {
+		if t == tenor {
+			return true
+		}
+	}
+	return false
+}
+
+func day(date time.Time) string {
+	return date.Format("2006-01-02")
+}
+
+func truncate(date time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+}
@@ -0,0 +1,173 @@
+package rates
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	boc "github.com/clauderoy790/bank-of-canada-interests-rates"
+	"github.com/clauderoy790/boc-excel-file-maker/provenance"
+	"github.com/clauderoy790/boc-excel-file-maker/treasury"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeBank struct {
+	boc.BOCInterests
+	observations map[string]*boc.Observations
+}
+
+func (b *fakeBank) GetObservationForDate(date string) (*boc.Observations, error) {
+	if obs, ok := b.observations[date]; ok {
+		return obs, nil
+	}
+	return nil, fmt.Errorf("no data for this date: %s", date)
+}
+
+type fakeMonth map[string]*treasury.Record
+
+func (m fakeMonth) GetRecordForDate(date string) (*treasury.Record, error) {
+	if r, ok := m[date]; ok {
+		return r, nil
+	}
+	return nil, fmt.Errorf("no record for date %s", date)
+}
+
+func date(d int) time.Time {
+	return time.Date(2023, time.June, d, 0, 0, 0, 0, time.Local)
+}
+
+func newTestClient() (*Client, *int) {
+	fetches := 0
+	c := NewClient()
+	c.fetchBank = func(ctx context.Context) (boc.BOCInterests, provenance.Record, error) {
+		fetches++
+		return &fakeBank{observations: map[string]*boc.Observations{
+			"2023-06-08": {D: "2023-06-08", Average1To3Year: boc.Val{V: "4.40"}, Yield2Year: boc.Val{V: "4.50"}, Yield3Year: boc.Val{V: "3.90"}, Yield5Year: boc.Val{V: "3.50"}},
+			"2023-06-09": {D: "2023-06-09", Yield2Year: boc.Val{V: "4.55"}, Yield3Year: boc.Val{V: "x"}},
+			"2023-06-12": {D: "2023-06-12", Yield2Year: boc.Val{V: "4.60"}, Yield3Year: boc.Val{V: "4.00"}, Yield5Year: boc.Val{V: "3.60"}},
+		}}, provenance.Record{Source: BoCSourceName, Unit: "bond_yields_all"}, nil
+	}
+	c.fetchMonth = func(ctx context.Context, month time.Time) (dayRecords, provenance.Record, error) {
+		fetches++
+		return fakeMonth{"2023-06-08": {Tenors: map[treasury.Tenor]float64{treasury.Year1: 5.2, treasury.Year4: 4.1}}},
+			provenance.Record{Source: treasury.SourceName, Unit: "2023-06"}, nil
+	}
+	return c, &fetches
+}
+
+func Test_RateAt(t *testing.T) {
+	a := assert.New(t)
+	c, fetches := newTestClient()
+	ctx := context.Background()
+
+	v, err := c.RateAt(ctx, OEC, Year5, date(8))
+	a.NoError(err)
+	a.Equal(3.5, v)
+	v, err = c.RateAt(ctx, OEC, Year4, date(8).Add(15*time.Hour))
+	a.NoError(err)
+	a.InDelta(3.7, v, 1e-9, "average of the 3 and 5 year yields")
+
+	_, err = c.RateAt(ctx, OEC, Year5, date(10))
+	a.True(errors.Is(err, ErrNoObservation), "saturday")
+	_, err = c.RateAt(ctx, OEC, Year7, date(8))
+	a.True(errors.Is(err, ErrNoObservation), "tenor not published that day")
+	_, err = c.RateAt(ctx, OEC, Month1, date(8))
+	a.Error(err)
+	a.False(errors.Is(err, ErrNoObservation), "not a tenor of the source")
+
+	// an invalid tenor leaves the others readable
+	v, err = c.RateAt(ctx, OEC, Year2, date(9))
+	a.NoError(err)
+	a.Equal(4.55, v)
+	var invalid *InvalidValueError
+	_, err = c.RateAt(ctx, OEC, Year3, date(9))
+	a.True(errors.As(err, &invalid))
+	a.Equal(Year3, invalid.Tenor)
+	_, err = c.RateAt(ctx, OEC, Year4, date(9))
+	a.True(errors.As(err, &invalid), "derived from the 3 year yield")
+
+	v, err = c.RateAt(ctx, Treasury, Year4, date(8))
+	a.NoError(err)
+	a.Equal(4.1, v)
+	_, err = c.RateAt(ctx, Treasury, Year1, date(9))
+	a.True(errors.Is(err, ErrNoObservation))
+	a.Equal(2, *fetches, "each source is fetched once")
+}
+
+func Test_Series(t *testing.T) {
+	a := assert.New(t)
+	c, _ := newTestClient()
+	series, err := c.Series(context.Background(), OEC, Year2, date(10), date(12))
+	a.NoError(err)
+	a.Equal([]Rate{{Date: date(12), Tenor: Year2, Value: 4.60}}, series, "the week-end is left out")
+
+	series, err = c.Series(context.Background(), OEC, Year2, date(8), date(12))
+	a.NoError(err)
+	a.Len(series, 3)
+	_, err = c.Series(context.Background(), OEC, Year3, date(8), date(12))
+	a.Error(err, "invalid value on the 9th")
+}
+
+func Test_Curve(t *testing.T) {
+	a := assert.New(t)
+	c, _ := newTestClient()
+	curve, err := c.Curve(context.Background(), OEC, date(12))
+	a.NoError(err)
+	a.Equal(map[Tenor]float64{Year2: 4.60, Year3: 4.00, Year4: 3.8, Year5: 3.60}, curve.Rates)
+	a.Empty(curve.Invalid)
+
+	curve, err = c.Curve(context.Background(), OEC, date(9))
+	a.NoError(err)
+	a.Equal(map[Tenor]float64{Year2: 4.55}, curve.Rates)
+	a.Contains(curve.Invalid, Year3)
+
+	_, err = c.Curve(context.Background(), "ecb", date(12))
+	a.Error(err)
+}
+
+func Test_Provenance(t *testing.T) {
+	a := assert.New(t)
+	c, fetches := newTestClient()
+	ctx := context.Background()
+	curve, err := c.Curve(ctx, Treasury, date(8))
+	a.NoError(err)
+	a.Equal("2023-06", curve.Provenance.Unit)
+
+	// the month of a day without observations still has its source
+	src, err := c.Provenance(ctx, Treasury, date(10))
+	a.NoError(err)
+	a.Equal(curve.Provenance, src)
+	a.Equal(1, *fetches)
+	_, err = c.Provenance(ctx, "ecb", date(8))
+	a.Error(err)
+}
+
+func Test_fetchBank(t *testing.T) {
+	a := assert.New(t)
+	body := []byte(`{"observations": [{"d": "2023-06-08", "BD.CDN.2YR.DQ.YLD": {"v": "4.50"}}]}`)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(body)
+	}))
+	defer srv.Close()
+	bank, src, err := fetchBank(context.Background(), srv.URL)
+	a.NoError(err)
+	a.Equal(http.StatusOK, src.Status)
+	a.Equal(provenance.Hash(body), src.Hash, "hash of the bytes received")
+	obs, err := bank.GetObservationForDate("2023-06-08")
+	a.NoError(err)
+	a.Equal("4.50", obs.Yield2Year.V)
+	_, err = bank.GetObservationForDate("2023-06-09")
+	a.Error(err)
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	_, src, err = fetchBank(context.Background(), failing.URL)
+	a.Error(err)
+	a.Equal(http.StatusServiceUnavailable, src.Status)
+}
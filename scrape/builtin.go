@@ -0,0 +1,50 @@
+package scrape
+
+// groups of definitions
+const (
+	// GroupCanadianPrime are the banks of the Canadian prime view, besides National Bank. None is
+	// built in, scrapers.example.json has definitions to start from.
+	GroupCanadianPrime = "canadian-prime"
+	// GroupOther are the rates of the configuration shown apart on the prime sheet.
+	GroupOther = ""
+	// GroupBuiltin are the pages with a place of their own in the workbook.
+	GroupBuiltin = "builtin"
+)
+
+// names of the built-in definitions and of their rates
+const (
+	BNC       = "bnc"
+	WSJ       = "wsj"
+	RateUS    = "us"
+	RateCAN   = "can"
+	RatePrime = "prime"
+)
+
+var builtins = []Definition{
+	{
+		Name:      BNC,
+		Source:    "Banque Nationale",
+		URL:       "https://www.bnc.ca/fr/taux-et-analyses/taux-dinteret-et-rendements/taux-de-base.html",
+		Group:     GroupBuiltin,
+		Selectors: []string{".nbc-table tbody", "tr"},
+		Rates: []Rate{
+			{Name: RateCAN, Label: `(?i)^(taux préférentiel|taux de base) (CA|CAN)\b`},
+			{Name: RateUS, Label: `(?i)^(taux préférentiel|taux de base) (US|USD)\b`},
+		},
+	},
+	{
+		Name:      WSJ,
+		Source:    "Wall Street Journal (fedprimerate.com)",
+		URL:       "http://www.fedprimerate.com/wall_street_journal_prime_rate_history.htm",
+		Group:     GroupBuiltin,
+		Selectors: []string{"tr", "td:nth-child(2)"},
+		Rates: []Rate{
+			{Name: RatePrime, Label: "^", Value: `(\d+(?:\.\d+)?)\s*%?\s*\(The Current U\.S\. Prime Rate\)`},
+		},
+	},
+}
+
+// Builtins returns the definitions shipped with the program.
+func Builtins() []Definition {
+	return append([]Definition{}, builtins...)
+}
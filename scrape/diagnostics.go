@@ -0,0 +1,77 @@
+package scrape
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Report describes what a run of the definition found, res and err being what Run returned.
+// It tells how many elements each selector matched and the text of the candidates so the
+// selectors can be fixed.
+func Report(d Definition, res *Result, err error) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Scraper: %s (%s)\n", d.Name, d.Source)
+	fmt.Fprintf(&b, "URL: %s\n", d.URL)
+	if err != nil {
+		fmt.Fprintf(&b, "Error: %v\n", err)
+	}
+	if res == nil {
+		return b.String()
+	}
+	b.WriteString("\nSelectors:\n")
+	for i, s := range d.Selectors {
+		matches := 0
+		if i < len(res.Matches) {
+			matches = res.Matches[i]
+		}
+		fmt.Fprintf(&b, "  %s: %d\n", s, matches)
+	}
+	b.WriteString("\nRates:\n")
+	for _, r := range d.Rates {
+		if v, ok := res.Values[r.Name]; ok {
+			fmt.Fprintf(&b, "  %s: %.4f\n", r.Name, v)
+		} else {
+			fmt.Fprintf(&b, "  %s: not found (label %q)\n", r.Name, r.Label)
+		}
+	}
+	fmt.Fprintf(&b, "\nCandidates (%d):\n", len(res.Candidates))
+	for i, c := range res.Candidates {
+		fmt.Fprintf(&b, "  %d. %s\n", i+1, c)
+	}
+	return b.String()
+}
+
+// SaveDiagnostics writes the page a run of the definition failed on and its report in dir,
+// named after the definition and the time of the run. It returns the path of the page.
+func SaveDiagnostics(dir string, d Definition, page []byte, res *Result, runErr error, at time.Time) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("error creating diagnostics folder: %w", err)
+	}
+	base := filepath.Join(dir, fmt.Sprintf("%s-%s", d.Name, at.Format("20060102-150405")))
+	if err := os.WriteFile(base+".html", page, 0644); err != nil {
+		return "", fmt.Errorf("error saving page: %w", err)
+	}
+	if err := os.WriteFile(base+".txt", []byte(Report(d, res, runErr)), 0644); err != nil {
+		return "", fmt.Errorf("error saving report: %w", err)
+	}
+	return base + ".html", nil
+}
+
+// Replay runs the definition against a saved page.
+func Replay(d Definition, path string) (*Result, error) {
+	page, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading snapshot: %w", err)
+	}
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(page))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing snapshot %s: %w", path, err)
+	}
+	return d.Run(doc)
+}
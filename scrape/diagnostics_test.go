@@ -0,0 +1,42 @@
+package scrape
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Diagnostics(t *testing.T) {
+	a := assert.New(t)
+	d, _ := Find(Builtins(), BNC)
+	d.Selectors = []string{".nbc-table tbody", "li"}
+	res, err := Replay(d, filepath.Join("testdata", "bnc.html"))
+	a.Error(err)
+	a.Equal([]int{1, 0}, res.Matches)
+
+	report := Report(d, res, err)
+	a.Contains(report, "Scraper: bnc (Banque Nationale)")
+	a.Contains(report, "  li: 0\n")
+	a.Contains(report, "  can: not found")
+	a.Contains(report, "Candidates (0):")
+
+	dir := t.TempDir()
+	page, _ := os.ReadFile(filepath.Join("testdata", "bnc.html"))
+	at := time.Date(2023, time.June, 8, 14, 30, 0, 0, time.UTC)
+	path, err := SaveDiagnostics(filepath.Join(dir, "diagnostics"), d, page, res, err, at)
+	a.NoError(err)
+	a.Equal(filepath.Join(dir, "diagnostics", "bnc-20230608-143000.html"), path)
+	saved, _ := os.ReadFile(strings.TrimSuffix(path, ".html") + ".txt")
+	a.Equal(report, string(saved))
+
+	// the fixed selectors work on the saved page
+	d.Selectors = []string{".nbc-table tbody", "tr"}
+	res, err = Replay(d, path)
+	a.NoError(err)
+	a.Equal(6.70, res.Values[RateCAN])
+	a.Contains(Report(d, res, nil), "  us: 8.2500\n")
+}
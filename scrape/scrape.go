@@ -0,0 +1,280 @@
+// Package scrape reads rates from HTML pages with definitions made of CSS selectors and
+// regular expressions, so a page redesign only needs a new definition.
+package scrape
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// units of the values of a page, converted to percent
+const (
+	Percent     = "percent"
+	Fraction    = "fraction"
+	BasisPoints = "bps"
+)
+
+// defaultValue matches the first number of a text, with a dot or a comma as decimal separator.
+const defaultValue = `(\d+(?:[.,]\d+)?)`
+
+// Definition describes a page and how to read its rates.
+type Definition struct {
+	// Name identifies the definition, a definition of the configuration replaces the built-in one with the same name.
+	Name string `json:"name"`
+	// Source is the name shown in the workbook and the provenance records.
+	Source string `json:"source"`
+	URL    string `json:"url"`
+	// Group tells where the rates go in the workbook, e.g. GroupCanadianPrime.
+	Group string `json:"group,omitempty"`
+	// Selectors are applied in turn, each within the elements matched by the previous one.
+	// The elements matched by the last one are the candidates holding the rates.
+	Selectors []string `json:"selectors"`
+	Rates     []Rate   `json:"rates"`
+}
+
+// Rate is read from the first candidate whose text matches Label, does not match Exclude
+// and has a value.
+type Rate struct {
+	Name    string `json:"name"`
+	Label   string `json:"label"`
+	Exclude string `json:"exclude,omitempty"`
+	// Value is searched in the text after the label, its first group is the number. It defaults to the first number.
+	Value string `json:"value,omitempty"`
+	// Units are the units of the value on the page: percent, fraction or bps. It defaults to percent.
+	Units string `json:"units,omitempty"`
+}
+
+// Result is what a definition found in a page.
+type Result struct {
+	// Values are the rates found, in percent, by rate name.
+	Values map[string]float64
+	// Matches are the number of elements matched by each selector of the chain.
+	Matches []int
+	// Candidates are the texts of the candidates, with their white space collapsed.
+	Candidates []string
+}
+
+// Validate checks that the definition is complete and its expressions compile.
+func (d Definition) Validate() error {
+	if d.Name == "" {
+		return fmt.Errorf("scraper without name")
+	}
+	if d.URL == "" {
+		return fmt.Errorf("scraper %s has no url", d.Name)
+	}
+	if len(d.Selectors) == 0 {
+		return fmt.Errorf("scraper %s has no selectors", d.Name)
+	}
+	if len(d.Rates) == 0 {
+		return fmt.Errorf("scraper %s has no rates", d.Name)
+	}
+	for _, r := range d.Rates {
+		if _, err := r.compile(); err != nil {
+			return fmt.Errorf("scraper %s: %w", d.Name, err)
+		}
+	}
+	return nil
+}
+
+type compiledRate struct {
+	Rate
+	label, exclude, value *regexp.Regexp
+}
+
+func (r Rate) compile() (*compiledRate, error) {
+	if r.Name == "" {
+		return nil, fmt.Errorf("rate without name")
+	}
+	c := &compiledRate{Rate: r}
+	var err error
+	if c.label, err = regexp.Compile(r.Label); err != nil {
+		return nil, fmt.Errorf("invalid label of rate %s: %w", r.Name, err)
+	}
+	if r.Exclude != "" {
+		if c.exclude, err = regexp.Compile(r.Exclude); err != nil {
+			return nil, fmt.Errorf("invalid exclude of rate %s: %w", r.Name, err)
+		}
+	}
+	value := r.Value
+	if value == "" {
+		value = defaultValue
+	}
+	if c.value, err = regexp.Compile(value); err != nil {
+		return nil, fmt.Errorf("invalid value of rate %s: %w", r.Name, err)
+	}
+	if c.value.NumSubexp() < 1 {
+		return nil, fmt.Errorf("value of rate %s has no group", r.Name)
+	}
+	switch r.Units {
+	case "", Percent, Fraction, BasisPoints:
+	default:
+		return nil, fmt.Errorf("invalid units %q of rate %s", r.Units, r.Name)
+	}
+	return c, nil
+}
+
+// percent converts a value in the units of the rate to percent.
+func (r *compiledRate) percent(v float64) float64 {
+	switch r.Units {
+	case Fraction:
+		return v * 100
+	case BasisPoints:
+		return v / 100
+	}
+	return v
+}
+
+// Run reads the rates of the definition in doc. The result has what was found even when
+// a rate is missing, to tell what the page looked like.
+func (d Definition) Run(doc *goquery.Document) (*Result, error) {
+	res := &Result{Values: make(map[string]float64)}
+	sel := doc.Selection
+	for _, s := range d.Selectors {
+		sel = sel.Find(s)
+		res.Matches = append(res.Matches, sel.Length())
+	}
+	sel.Each(func(i int, s *goquery.Selection) {
+		res.Candidates = append(res.Candidates, text(s))
+	})
+	var missing []string
+	for _, r := range d.Rates {
+		c, err := r.compile()
+		if err != nil {
+			return res, fmt.Errorf("scraper %s: %w", d.Name, err)
+		}
+		v, found, err := c.find(res.Candidates)
+		if err != nil {
+			return res, fmt.Errorf("scraper %s: %w", d.Name, err)
+		}
+		if !found {
+			missing = append(missing, r.Name)
+			continue
+		}
+		res.Values[r.Name] = v
+	}
+	if len(missing) > 0 {
+		return res, fmt.Errorf("scraper %s found no %s in %d candidates of %s", d.Name, strings.Join(missing, ", "), len(res.Candidates), d.URL)
+	}
+	return res, nil
+}
+
+// text returns the text of the elements with their white space collapsed, the texts of
+// different nodes being separated so that cells don't run together.
+func text(s *goquery.Selection) string {
+	var words []string
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			words = append(words, strings.Fields(n.Data)...)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	for _, n := range s.Nodes {
+		walk(n)
+	}
+	return strings.Join(words, " ")
+}
+
+func (r *compiledRate) find(candidates []string) (float64, bool, error) {
+	for _, text := range candidates {
+		loc := r.label.FindStringIndex(text)
+		if loc == nil || (r.exclude != nil && r.exclude.MatchString(text)) {
+			continue
+		}
+		m := r.value.FindStringSubmatch(text[loc[1]:])
+		if m == nil {
+			continue
+		}
+		v, err := strconv.ParseFloat(strings.ReplaceAll(m[1], ",", "."), 64)
+		if err != nil {
+			return 0, false, fmt.Errorf("invalid %s value %q: %w", r.Name, m[1], err)
+		}
+		return r.percent(v), true, nil
+	}
+	return 0, false, nil
+}
+
+// Load reads definitions from a JSON file holding a list of them.
+func Load(path string) ([]Definition, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading scrapers: %w", err)
+	}
+	var defs []Definition
+	if err := json.Unmarshal(content, &defs); err != nil {
+		return nil, fmt.Errorf("error reading scrapers %s: %w", path, err)
+	}
+	for _, d := range defs {
+		if err := d.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return defs, nil
+}
+
+// Merge returns defs with the definitions of custom replacing the ones with the same name,
+// the other definitions of custom being added after them. A replacement keeps the group of the
+// definition it replaces, so a built-in page keeps its place in the workbook, and must have all its rates.
+func Merge(defs, custom []Definition) ([]Definition, error) {
+	merged := append([]Definition{}, defs...)
+	for _, c := range custom {
+		replaced := false
+		for i, d := range merged {
+			if d.Name != c.Name {
+				continue
+			}
+			for _, r := range d.Rates {
+				if _, ok := c.rate(r.Name); !ok {
+					return nil, fmt.Errorf("scraper %s replaces one with a rate %s but has none", c.Name, r.Name)
+				}
+			}
+			c.Group = d.Group
+			merged[i] = c
+			replaced = true
+		}
+		if !replaced {
+			merged = append(merged, c)
+		}
+	}
+	return merged, nil
+}
+
+// rate returns the rate of the definition with a name.
+func (d Definition) rate(name string) (Rate, bool) {
+	for _, r := range d.Rates {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return Rate{}, false
+}
+
+// Find returns the definition with a name.
+func Find(defs []Definition, name string) (Definition, bool) {
+	for _, d := range defs {
+		if d.Name == name {
+			return d, true
+		}
+	}
+	return Definition{}, false
+}
+
+// InGroup returns the definitions of a group, in order.
+func InGroup(defs []Definition, group string) []Definition {
+	var in []Definition
+	for _, d := range defs {
+		if d.Group == group {
+			in = append(in, d)
+		}
+	}
+	return in
+}
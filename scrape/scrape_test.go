@@ -0,0 +1,132 @@
+package scrape
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/stretchr/testify/assert"
+)
+
+func readDocument(t *testing.T, name string) *goquery.Document {
+	content, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(string(content)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func Test_Builtins(t *testing.T) {
+	a := assert.New(t)
+	for _, d := range Builtins() {
+		a.NoError(d.Validate(), d.Name)
+	}
+
+	bnc, ok := Find(Builtins(), BNC)
+	a.True(ok)
+	res, err := bnc.Run(readDocument(t, "bnc.html"))
+	a.NoError(err)
+	a.Equal(map[string]float64{RateCAN: 6.70, RateUS: 8.25}, res.Values)
+	a.Equal([]int{1, 5}, res.Matches)
+
+	wsj, _ := Find(Builtins(), WSJ)
+	res, err = wsj.Run(readDocument(t, "wsj.html"))
+	a.NoError(err)
+	a.Equal(8.25, res.Values[RatePrime])
+
+	a.Empty(InGroup(Builtins(), GroupCanadianPrime), "the bank pages are not verified")
+}
+
+func Test_Example(t *testing.T) {
+	a := assert.New(t)
+	example, err := Load(filepath.Join("..", "scrapers.example.json"))
+	a.NoError(err)
+	merged, err := Merge(Builtins(), example)
+	a.NoError(err)
+	a.Len(InGroup(merged, GroupCanadianPrime), 6)
+}
+
+func Test_Run(t *testing.T) {
+	a := assert.New(t)
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(
+		`<ul><li>Overnight: 0.0475</li><li>Spread: 25 bps</li><li>Prime: n/a</li></ul>`))
+	a.NoError(err)
+
+	d := Definition{Name: "test", URL: "http://test", Selectors: []string{"ul", "li"}, Rates: []Rate{
+		{Name: "overnight", Label: "Overnight", Units: Fraction},
+		{Name: "spread", Label: "Spread", Units: BasisPoints},
+	}}
+	res, err := d.Run(doc)
+	a.NoError(err)
+	a.InDelta(4.75, res.Values["overnight"], 1e-9)
+	a.InDelta(0.25, res.Values["spread"], 1e-9)
+	a.Equal([]string{"Overnight: 0.0475", "Spread: 25 bps", "Prime: n/a"}, res.Candidates)
+
+	d.Rates = append(d.Rates, Rate{Name: "prime", Label: "Prime"})
+	res, err = d.Run(doc)
+	a.Error(err)
+	a.Len(res.Values, 2, "the rates found are kept")
+
+	d.Selectors = []string{"table"}
+	res, err = d.Run(doc)
+	a.Error(err)
+	a.Equal([]int{0}, res.Matches)
+}
+
+func Test_Validate(t *testing.T) {
+	a := assert.New(t)
+	valid := Definition{Name: "x", URL: "http://x", Selectors: []string{"tr"}, Rates: []Rate{{Name: "r", Label: "R"}}}
+	a.NoError(valid.Validate())
+
+	for name, change := range map[string]func(d *Definition){
+		"no name":      func(d *Definition) { d.Name = "" },
+		"no url":       func(d *Definition) { d.URL = "" },
+		"no selectors": func(d *Definition) { d.Selectors = nil },
+		"no rates":     func(d *Definition) { d.Rates = nil },
+		"bad label":    func(d *Definition) { d.Rates = []Rate{{Name: "r", Label: "("}} },
+		"no group":     func(d *Definition) { d.Rates = []Rate{{Name: "r", Label: "R", Value: `\d+`}} },
+		"bad units":    func(d *Definition) { d.Rates = []Rate{{Name: "r", Label: "R", Units: "%"}} },
+	} {
+		d := valid
+		change(&d)
+		a.Error(d.Validate(), name)
+	}
+}
+
+func Test_LoadMerge(t *testing.T) {
+	a := assert.New(t)
+	path := filepath.Join(t.TempDir(), "scrapers.json")
+	a.NoError(os.WriteFile(path, []byte(`[
+		{"name": "wsj", "source": "WSJ", "url": "http://wsj", "selectors": ["td"], "rates": [{"name": "prime", "label": "Prime"}]},
+		{"name": "hsbc", "source": "HSBC", "url": "http://hsbc", "group": "canadian-prime", "selectors": ["td"], "rates": [{"name": "prime", "label": "Prime"}]}
+	]`), 0o644))
+	custom, err := Load(path)
+	a.NoError(err)
+	a.Len(custom, 2)
+
+	merged, err := Merge(Builtins(), custom)
+	a.NoError(err)
+	a.Len(merged, len(Builtins())+1)
+	wsj, _ := Find(merged, WSJ)
+	a.Equal("http://wsj", wsj.URL)
+	a.Equal(GroupBuiltin, wsj.Group, "a replaced built-in keeps its group")
+	for _, d := range InGroup(merged, GroupOther) {
+		a.NotEqual(WSJ, d.Name)
+	}
+	a.Equal("hsbc", merged[len(merged)-1].Name)
+	a.Len(InGroup(merged, GroupCanadianPrime), 1)
+
+	// a replaced built-in must have the rates the program reads
+	_, err = Merge(Builtins(), []Definition{{Name: WSJ, URL: "http://wsj", Selectors: []string{"td"}, Rates: []Rate{{Name: "rate", Label: "Prime"}}}})
+	a.Error(err)
+
+	a.NoError(os.WriteFile(path, []byte(`[{"name": "x"}]`), 0o644))
+	_, err = Load(path)
+	a.Error(err)
+}
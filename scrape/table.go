@@ -0,0 +1,195 @@
+package scrape
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Currency of a rate of a table.
+type Currency string
+
+const (
+	CAD Currency = "CAD"
+	USD Currency = "USD"
+	// UnknownCurrency is the currency of a rate whose label names none or both.
+	UnknownCurrency Currency = ""
+)
+
+// TableRate is a row of a table of rates, as published.
+type TableRate struct {
+	// Name is the name of the rate of the definition read from the row, empty for the other rows.
+	Name     string
+	Label    string
+	Currency Currency
+	Percent  float64
+	// Effective is the date the rate is in effect from, zero when the row has none.
+	Effective time.Time
+	// Published is the text of the effective date.
+	Published string
+}
+
+var (
+	cadRegexp  = regexp.MustCompile(`(?i)(^|[^\pL])(CA|CAN|CAD|canadien(ne)?s?|canadian)([^\pL]|$)`)
+	usdRegexp  = regexp.MustCompile(`(?i)(^|[^\pL])(US|USD|É\.-U\.|américain(e)?s?|american)([^\pL]|$)`)
+	rateRegexp = regexp.MustCompile(`^(\d+(?:[.,]\d+)?)\s*%?$`)
+)
+
+// currencyOf tells the currency named by a label, unknown when it names none or both so that
+// a rate is never taken for the prime of the wrong currency.
+func currencyOf(label string) Currency {
+	cad, usd := cadRegexp.MatchString(label), usdRegexp.MatchString(label)
+	switch {
+	case cad && !usd:
+		return CAD
+	case usd && !cad:
+		return USD
+	}
+	return UnknownCurrency
+}
+
+// Table reads every row of the elements matched by the selectors of the definition as a rate:
+// the first cell is the label, the first number is the rate and a date is its effective date.
+// Rows without a label and a rate, like headers, are skipped. Each rate of the definition names
+// the first row it matches, its value read as by Run, and must match one.
+func (d Definition) Table(doc *goquery.Document) ([]TableRate, error) {
+	sel := doc.Selection
+	for _, s := range d.Selectors {
+		sel = sel.Find(s)
+	}
+	named := make([]*compiledRate, len(d.Rates))
+	for i, r := range d.Rates {
+		c, err := r.compile()
+		if err != nil {
+			return nil, fmt.Errorf("scraper %s: %w", d.Name, err)
+		}
+		named[i] = c
+	}
+	var rates []TableRate
+	var err error
+	sel.EachWithBreak(func(i int, row *goquery.Selection) bool {
+		var cells []string
+		row.Find("td, th").Each(func(j int, c *goquery.Selection) {
+			cells = append(cells, text(c))
+		})
+		if len(cells) < 2 {
+			return true
+		}
+		r := TableRate{Label: cells[0], Currency: currencyOf(cells[0])}
+		found := false
+		for _, c := range cells[1:] {
+			if m := rateRegexp.FindStringSubmatch(c); m != nil && !found {
+				if r.Percent, err = strconv.ParseFloat(strings.ReplaceAll(m[1], ",", "."), 64); err != nil {
+					err = fmt.Errorf("invalid rate %q of %s: %w", c, r.Label, err)
+					return false
+				}
+				found = true
+			} else if t, ok := parseDate(c); ok && r.Published == "" {
+				r.Effective, r.Published = t, c
+			}
+		}
+		for i, c := range named {
+			if c == nil {
+				continue
+			}
+			v, ok, findErr := c.find([]string{text(row)})
+			if findErr != nil {
+				err = findErr
+				return false
+			}
+			if ok {
+				r.Name, r.Percent, found = c.Name, v, true
+				named[i] = nil
+				break
+			}
+		}
+		if found {
+			rates = append(rates, r)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scraper %s: %w", d.Name, err)
+	}
+	if len(rates) == 0 {
+		return nil, fmt.Errorf("scraper %s found no rates in %d rows of %s", d.Name, sel.Length(), d.URL)
+	}
+	var missing []string
+	for i, c := range named {
+		if c != nil {
+			missing = append(missing, d.Rates[i].Name)
+		}
+	}
+	if len(missing) > 0 {
+		return rates, fmt.Errorf("scraper %s found no %s in %d rates of %s", d.Name, strings.Join(missing, ", "), len(rates), d.URL)
+	}
+	return rates, nil
+}
+
+// Named returns the rate of a table read by the rate of its definition with a name.
+func Named(rates []TableRate, name string) (TableRate, bool) {
+	for _, r := range rates {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return TableRate{}, false
+}
+
+// leadingRateRegexp matches a rate at the start of a cell, which may be followed by a note.
+var leadingRateRegexp = regexp.MustCompile(`^(\d+(?:[.,]\d+)?)\s*%?(?:\s|\(|$)`)
+
+// History reads the history of a rate from the rows of a page that have a date and a rate,
+// possibly followed by a note like "(The Current U.S. Prime Rate)", oldest first.
+// The other rows, like headers, are skipped.
+func History(doc *goquery.Document) []TableRate {
+	var rates []TableRate
+	doc.Find("tr").Each(func(i int, row *goquery.Selection) {
+		var r TableRate
+		hasRate := false
+		row.Find("td, th").Each(func(j int, c *goquery.Selection) {
+			cell := text(c)
+			if t, ok := parseDate(cell); ok && r.Published == "" {
+				r.Effective, r.Published, r.Label = t, cell, cell
+			} else if m := leadingRateRegexp.FindStringSubmatch(cell); m != nil && !hasRate {
+				v, err := strconv.ParseFloat(strings.ReplaceAll(m[1], ",", "."), 64)
+				r.Percent, hasRate = v, err == nil
+			}
+		})
+		if hasRate && r.Published != "" {
+			rates = append(rates, r)
+		}
+	})
+	sort.SliceStable(rates, func(i, j int) bool { return rates[i].Effective.Before(rates[j].Effective) })
+	return rates
+}
+
+var frenchMonths = map[string]time.Month{
+	"janvier": time.January, "février": time.February, "mars": time.March, "avril": time.April,
+	"mai": time.May, "juin": time.June, "juillet": time.July, "août": time.August,
+	"septembre": time.September, "octobre": time.October, "novembre": time.November, "décembre": time.December,
+}
+
+var frenchDateRegexp = regexp.MustCompile(`^(\d{1,2})(?:er)?\s+(\pL+)\s+(\d{4})$`)
+
+// parseDate reads the dates of the pages, in French like "8 juin 2023" or in English.
+func parseDate(s string) (time.Time, bool) {
+	if m := frenchDateRegexp.FindStringSubmatch(s); m != nil {
+		if month, ok := frenchMonths[strings.ToLower(m[2])]; ok {
+			day, _ := strconv.Atoi(m[1])
+			year, _ := strconv.Atoi(m[3])
+			return time.Date(year, month, day, 0, 0, 0, 0, time.Local), true
+		}
+	}
+	for _, layout := range []string{"2006-01-02", "January 2, 2006", "Jan 2, 2006"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
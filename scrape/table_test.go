@@ -0,0 +1,79 @@
+package scrape
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Table(t *testing.T) {
+	a := assert.New(t)
+	bnc, _ := Find(Builtins(), BNC)
+	rates, err := bnc.Table(readDocument(t, "bnc.html"))
+	a.NoError(err)
+	a.Equal([]TableRate{
+		{Name: RateCAN, Label: "Taux préférentiel CA", Currency: CAD, Percent: 6.70, Effective: time.Date(2023, time.June, 8, 0, 0, 0, 0, time.Local), Published: "8 juin 2023"},
+		{Name: RateUS, Label: "Taux de base US", Currency: USD, Percent: 8.25, Effective: time.Date(2023, time.May, 4, 0, 0, 0, 0, time.Local), Published: "4 mai 2023"},
+		{Label: "Taux de référence hypothécaire CA", Currency: CAD, Percent: 6.95, Effective: time.Date(2023, time.June, 8, 0, 0, 0, 0, time.Local), Published: "8 juin 2023"},
+		{Label: "Taux d'escompte", Currency: UnknownCurrency, Percent: 4.75, Effective: time.Date(2023, time.June, 7, 0, 0, 0, 0, time.Local), Published: "7 juin 2023"},
+	}, rates)
+
+	can, ok := Named(rates, RateCAN)
+	a.True(ok)
+	a.Equal(6.70, can.Percent)
+	us, ok := Named(rates, RateUS)
+	a.True(ok)
+	a.Equal(8.25, us.Percent)
+
+	// the rows without the primes have no name to be found by
+	_, ok = Named(rates[2:], RateUS)
+	a.False(ok)
+
+	// the rates of a replacing definition name the rows, with their units
+	custom := bnc
+	custom.Rates = []Rate{
+		{Name: RateCAN, Label: "(?i)hypothécaire", Units: Percent},
+		{Name: RateUS, Label: "(?i)escompte", Value: `(\d+(?:\.\d+)?)`, Units: Fraction},
+	}
+	rates, err = custom.Table(readDocument(t, "bnc.html"))
+	a.NoError(err)
+	can, _ = Named(rates, RateCAN)
+	a.Equal("Taux de référence hypothécaire CA", can.Label)
+	us, _ = Named(rates, RateUS)
+	a.InDelta(475, us.Percent, 1e-9)
+
+	custom.Rates = append(custom.Rates, Rate{Name: "missing", Label: "Absent"})
+	_, err = custom.Table(readDocument(t, "bnc.html"))
+	a.Error(err, "a rate of the definition matches no row")
+
+	bnc.Selectors = []string{"ul", "li"}
+	_, err = bnc.Table(readDocument(t, "bnc.html"))
+	a.Error(err)
+}
+
+func Test_History(t *testing.T) {
+	a := assert.New(t)
+	rates := History(readDocument(t, "wsj.html"))
+	a.Equal([]TableRate{
+		{Label: "February 2, 2023", Percent: 7.75, Effective: time.Date(2023, time.February, 2, 0, 0, 0, 0, time.Local), Published: "February 2, 2023"},
+		{Label: "March 23, 2023", Percent: 8.00, Effective: time.Date(2023, time.March, 23, 0, 0, 0, 0, time.Local), Published: "March 23, 2023"},
+		{Label: "May 4, 2023", Percent: 8.25, Effective: time.Date(2023, time.May, 4, 0, 0, 0, 0, time.Local), Published: "May 4, 2023"},
+	}, rates)
+}
+
+func Test_currencyOf(t *testing.T) {
+	a := assert.New(t)
+	for label, want := range map[string]Currency{
+		"Taux préférentiel CA":       CAD,
+		"Taux de base US":            USD,
+		"Taux de base en dollars US": USD,
+		"Prime canadien":             CAD,
+		"Taux de base américain":     USD,
+		"CASH rate":                  UnknownCurrency,
+		"Taux CA / US":               UnknownCurrency,
+		"Taux d'escompte":            UnknownCurrency,
+	} {
+		a.Equal(want, currencyOf(label), label)
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/clauderoy790/boc-excel-file-maker/quality"
+	"github.com/clauderoy790/boc-excel-file-maker/scrape"
+	"github.com/xuri/excelize/v2"
+)
+
+// scrapers are the definitions of the scraped pages, the built-in ones with those of the -scrapers file.
+var scrapers = scrape.Builtins()
+
+// loadScrapers adds the definitions of a file to the built-in ones, replacing those with the same name.
+func loadScrapers(path string) error {
+	custom, err := scrape.Load(path)
+	if err != nil {
+		return err
+	}
+	merged, err := scrape.Merge(scrape.Builtins(), custom)
+	if err != nil {
+		return fmt.Errorf("error loading scrapers %s: %w", path, err)
+	}
+	scrapers = merged
+	return nil
+}
+
+// scraper returns the definition with a name, the built-in ones are always there.
+func scraper(name string) scrape.Definition {
+	d, _ := scrape.Find(scrapers, name)
+	return d
+}
+
+// diagnosticsDir is where the pages that failed to scrape are saved, next to the executable when empty.
+var diagnosticsDir string
+
+// runScraper fetches the page of a definition and reads its rates. When the rates are not all
+// in the page, the page and a report are saved in diagnosticsDir to fix the definition with replay.
+func runScraper(ctx context.Context, d scrape.Definition) (*scrape.Result, error) {
+	page, doc, err := fetchScraped(ctx, d)
+	if err != nil {
+		return nil, err
+	}
+	res, err := d.Run(doc)
+	if err != nil {
+		saveDiagnostics(d, page, res, err)
+	}
+	return res, err
+}
+
+// fetchScraped gets and parses the page of a definition. A page that cannot be fetched is saved in
+// diagnosticsDir too, with the body of the response when there is one, like a block page or a 403.
+func fetchScraped(ctx context.Context, d scrape.Definition) ([]byte, *goquery.Document, error) {
+	page, err := fetchPage(ctx, d.Source, d.URL)
+	if err != nil {
+		if ctx.Err() == nil {
+			saveDiagnostics(d, page, nil, err)
+		}
+		return nil, nil, err
+	}
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(page))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create document: %w", err)
+	}
+	return page, doc, nil
+}
+
+// getBNRates scrapes every rate of the table of National Bank, which must have the US and
+// Canadian primes of its definition. Like runScraper, a page without them is saved in diagnosticsDir.
+func getBNRates(ctx context.Context) ([]scrape.TableRate, error) {
+	d := scraper(scrape.BNC)
+	page, doc, err := fetchScraped(ctx, d)
+	if err != nil {
+		return nil, err
+	}
+	rates, err := d.Table(doc)
+	if err != nil {
+		res, _ := d.Run(doc)
+		saveDiagnostics(d, page, res, err)
+		return nil, err
+	}
+	return rates, nil
+}
+
+// saveDiagnostics keeps a failed page, only logging when it can't since the run failing matters more.
+func saveDiagnostics(d scrape.Definition, page []byte, res *scrape.Result, runErr error) {
+	dir := diagnosticsDir
+	if dir == "" {
+		ex, err := os.Executable()
+		if err != nil {
+			slog.Warn("unable to save scraper diagnostics", "source", d.Source, "error", err)
+			return
+		}
+		dir = filepath.Join(filepath.Dir(ex), "diagnostics")
+	}
+	path, err := scrape.SaveDiagnostics(dir, d, page, res, runErr, time.Now())
+	if err != nil {
+		slog.Warn("unable to save scraper diagnostics", "source", d.Source, "error", err)
+		return
+	}
+	slog.Warn("saved scraper diagnostics", "source", d.Source, "page", path)
+}
+
+// replayScraper runs a scraper against a page saved by a failed run and prints its report.
+func replayScraper(w io.Writer, name, path string) error {
+	d, ok := scrape.Find(scrapers, name)
+	if !ok {
+		return fmt.Errorf("unknown scraper %q", name)
+	}
+	res, err := scrape.Replay(d, path)
+	fmt.Fprint(w, scrape.Report(d, res, err))
+	return err
+}
+
+// otherRate is a rate of a definition without a place of its own in the workbook.
+type otherRate struct {
+	source, name string
+	rate         float64
+	found        bool
+}
+
+// getOtherRates scrapes the definitions of the configuration that are in no group. Like the
+// Canadian banks, a page that fails is left out and only a cancelled run fails.
+func getOtherRates(ctx context.Context, progress progressFunc) ([]otherRate, error) {
+	defs := scrape.InGroup(scrapers, scrape.GroupOther)
+	var rates []otherRate
+	progress(stepScrapers, 0, len(defs))
+	for i, d := range defs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		res, err := runScraper(ctx, d)
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, ctxErr
+			}
+			slog.Warn("failed to scrape rates", "source", d.Source, "step", stepScrapers, "error", err)
+		}
+		for _, r := range d.Rates {
+			o := otherRate{source: d.Source, name: r.Name}
+			if res != nil {
+				o.rate, o.found = res.Values[r.Name]
+			}
+			if o.found {
+				slog.Info("scraped rate", "source", d.Source, "step", stepScrapers, "rate", r.Name, "value", o.rate)
+			}
+			rates = append(rates, o)
+		}
+		progress(stepScrapers, i+1, len(defs))
+	}
+	return rates, nil
+}
+
+// writeOtherRates writes the rates of the configured scrapers right of the Canadian primes.
+func writeOtherRates(f *excelize.File, date time.Time, rates []otherRate) ([]*quality.Series, error) {
+	sheet := wsjSheet
+	for _, cell := range [][2]string{
+		{"R1", "Autres taux"},
+		{"R2", "Taux en date du: " + wsjDate(date)},
+	} {
+		if err := setCell(f, sheet, cell[0], cell[1]); err != nil {
+			return nil, err
+		}
+	}
+	if err := setRow(f, sheet, "R4", []interface{}{"Source", "Taux", "Valeur"}); err != nil {
+		return nil, err
+	}
+	var series []*quality.Series
+	for i, r := range rates {
+		if err := setRow(f, sheet, fmt.Sprintf("R%d", 5+i), []interface{}{r.source, r.name, percentOr(r.rate, r.found)}); err != nil {
+			return nil, err
+		}
+		if r.found {
+			s := &quality.Series{Sheet: sheet, Name: r.source + " " + r.name, Percent: true}
+			s.Add(date, r.rate)
+			series = append(series, s)
+		}
+	}
+	return series, nil
+}
+
+// writeBNCRates writes every rate of the table of National Bank right of the other rates.
+func writeBNCRates(f *excelize.File, date time.Time, rates []scrape.TableRate) ([]*quality.Series, error) {
+	sheet := wsjSheet
+	for _, cell := range [][2]string{
+		{"V1", "Taux BNC"},
+		{"V2", "Taux en date du: " + wsjDate(date)},
+	} {
+		if err := setCell(f, sheet, cell[0], cell[1]); err != nil {
+			return nil, err
+		}
+	}
+	if err := setRow(f, sheet, "V4", []interface{}{"Taux", "Devise", "Valeur", "En vigueur"}); err != nil {
+		return nil, err
+	}
+	var series []*quality.Series
+	for i, r := range rates {
+		currency := string(r.Currency)
+		if r.Currency == scrape.UnknownCurrency {
+			currency = "n/a"
+		}
+		effective := r.Published
+		if !r.Effective.IsZero() {
+			effective = wsjDate(r.Effective)
+		}
+		if err := setRow(f, sheet, fmt.Sprintf("V%d", 5+i), []interface{}{r.Label, currency, percent(r.Percent), effective}); err != nil {
+			return nil, err
+		}
+		s := &quality.Series{Sheet: sheet, Name: "BNC " + r.Label, Percent: true}
+		s.Add(date, r.Percent)
+		series = append(series, s)
+	}
+	return series, nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/clauderoy790/boc-excel-file-maker/provenance"
+	"github.com/xuri/excelize/v2"
+)
+
+const sourcesSheet = "Sources"
+
+func writeSourcesSheet(f *excelize.File, records []provenance.Record) error {
+	sheet := sourcesSheet
+	f.NewSheet(sheet)
+
+	if err := f.SetCellValue(sheet, "A1", "Provenance des données"); err != nil {
+		return fmt.Errorf("error setting sheet value: %w", err)
+	}
+	if err := f.SetCellValue(sheet, "A2", fmt.Sprintf("Généré le %s", time.Now().Format(time.RFC3339))); err != nil {
+		return fmt.Errorf("error setting sheet value: %w", err)
+	}
+	header := []interface{}{"Source", "Unit", "URL", "Fetched at", "HTTP status", "Cache", "SHA-256", "Note"}
+	if err := f.SetSheetRow(sheet, "A4", &header); err != nil {
+		return fmt.Errorf("error setting header: %w", err)
+	}
+	for i, r := range records {
+		status := ""
+		if r.Status != 0 {
+			status = fmt.Sprintf("%d", r.Status)
+		}
+		cache := string(r.Cache)
+		if r.Cache == provenance.NoCache {
+			cache = "none"
+		}
+		row := []interface{}{r.Source, r.Unit, r.URL, r.FetchedAt.Format(time.RFC3339), status, cache, r.Hash, r.Note}
+		if err := f.SetSheetRow(sheet, fmt.Sprintf("A%d", i+5), &row); err != nil {
+			return fmt.Errorf("error setting source row: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/clauderoy790/boc-excel-file-maker/quality"
+	"github.com/clauderoy790/boc-excel-file-maker/xltemplate"
+	"github.com/xuri/excelize/v2"
+)
+
+// sheetKeys name the sheets in templates and defined names, other sheets are named after their title.
+var sheetKeys = map[string]string{
+	oecSheet:          "oec",
+	fxSheet:           "fx",
+	treasSheet:        "ust",
+	realYieldSheet:    "ust_real",
+	billsSheet:        "ust_bills",
+	longTermSheet:     "ust_long",
+	realLongTermSheet: "ust_real_long",
+	wsjSheet:          "prime",
+	policySheet:       "policy",
+}
+
+var (
+	accents     = strings.NewReplacer("é", "e", "è", "e", "ê", "e", "à", "a", "â", "a", "ç", "c", "ô", "o", "û", "u", "î", "i", "É", "e")
+	yearsRegexp = regexp.MustCompile(`(\d+) (ans?|years?)\b`)
+	weeksRegexp = regexp.MustCompile(`(\d+) sem\.?`)
+	wordRegexp  = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// slug turns a title into a key: lower case letters and digits separated by underscores,
+// years and weeks shortened so that "5 ans" is 5y and "13 sem." is 13w.
+func slug(title string) string {
+	s := accents.Replace(strings.ToLower(title))
+	s = yearsRegexp.ReplaceAllString(s, "${1}y")
+	s = weeksRegexp.ReplaceAllString(s, "${1}w")
+	return strings.Trim(wordRegexp.ReplaceAllString(s, "_"), "_")
+}
+
+// sheetKey returns the key of a sheet, like oec.
+func sheetKey(sheet string) string {
+	if key, ok := sheetKeys[sheet]; ok {
+		return key
+	}
+	return slug(sheet)
+}
+
+// templateSources groups the series by sheet, in the order of the workbook. The rates are
+// fractions like in the sheets, the other series keep their values.
+func templateSources(series []*quality.Series) []xltemplate.Source {
+	var sources []xltemplate.Source
+	index := make(map[string]int)
+	for _, s := range series {
+		key := sheetKey(s.Sheet)
+		i, ok := index[key]
+		if !ok {
+			i = len(sources)
+			index[key] = i
+			sources = append(sources, xltemplate.Source{Key: key})
+		}
+		ts := xltemplate.Series{Key: slug(s.Name), Title: s.Name}
+		for _, o := range s.Observations {
+			v := o.Value
+			if s.Percent {
+				v /= 100
+			}
+			ts.Observations = append(ts.Observations, xltemplate.Observation{Date: o.Date, Value: v})
+		}
+		sort.Slice(ts.Observations, func(i, j int) bool { return ts.Observations[i].Date.Before(ts.Observations[j].Date) })
+		sources[i].Series = append(sources[i].Series, ts)
+	}
+	return sources
+}
+
+// saveTemplate fills the template at path with the series of the workbook and saves it as output,
+// the template itself is left as it is.
+func (wb *workbook) saveTemplate(path, output string) error {
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		return &stepError{step: stepWriting, err: fmt.Errorf("error opening template: %w", err)}
+	}
+	defer f.Close()
+	res, err := xltemplate.Fill(f, templateSources(wb.series))
+	if err != nil {
+		return &stepError{step: stepWriting, detail: path, err: fmt.Errorf("error filling template: %w", err)}
+	}
+	slog.Info("filled template", "template", path, "placeholders", res.Placeholders, "cells", res.Cells, "tables", res.Tables, "rows", res.Rows)
+	if err := f.SaveAs(output); err != nil {
+		return &stepError{step: stepWriting, err: fmt.Errorf("failed to write file: %w", err)}
+	}
+	return nil
+}
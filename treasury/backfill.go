@@ -0,0 +1,193 @@
+package treasury
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/clauderoy790/boc-excel-file-maker/cache"
+	"github.com/clauderoy790/boc-excel-file-maker/provenance"
+)
+
+const csvBaseURL = "https://home.treasury.gov/resource-center/data-chart-center/interest-rates/daily-treasury-rates.csv/"
+
+// YearURL returns the address of the data of a whole year.
+func (ds Dataset) YearURL(year int) string {
+	return fmt.Sprintf("%s%s&field_tdr_date_value=%04d", baseURL, ds, year)
+}
+
+// CSVURL returns the address of the CSV archive of a year.
+func (ds Dataset) CSVURL(year int) string {
+	return fmt.Sprintf("%s%04d/all?type=%s&field_tdr_date_value=%04d&page&_format=csv", csvBaseURL, year, ds, year)
+}
+
+// csvPrefixes are the prefixes of the tenors of the datasets whose CSV archives can be read.
+var csvPrefixes = map[Dataset]string{YieldCurve: "BC_", RealYieldCurve: "TC_"}
+
+// csvColumn matches the tenor columns of the CSV archives, e.g. 1 Mo, 1.5 Month or 30 YR.
+var csvColumn = regexp.MustCompile(`(?i)^([\d.]+)\s*(mo|month|wk|week|yr|year)s?$`)
+
+var csvUnits = map[string]string{"mo": "MONTH", "month": "MONTH", "wk": "WEEK", "week": "WEEK", "yr": "YEAR", "year": "YEAR"}
+
+// csvEntries returns the entries of a CSV archive as those of the XML feed, the columns that are not tenors are left out.
+func csvEntries(ds Dataset, data []byte) ([][]field, error) {
+	prefix, ok := csvPrefixes[ds]
+	if !ok {
+		return nil, fmt.Errorf("no CSV archive for %s", ds)
+	}
+	rows, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("error reading CSV: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	names := make([]string, len(rows[0]))
+	for i, col := range rows[0] {
+		if m := csvColumn.FindStringSubmatch(strings.TrimSpace(col)); m != nil {
+			names[i] = prefix + strings.ReplaceAll(m[1], ".", "_") + csvUnits[strings.ToLower(m[2])]
+		}
+	}
+	var all [][]field
+	for _, row := range rows[1:] {
+		if len(row) == 0 {
+			continue
+		}
+		d, err := time.Parse("01/02/2006", strings.TrimSpace(row[0]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid date format: %q", row[0])
+		}
+		fields := []field{{XMLName: xml.Name{Local: dateField}, Value: d.Format("2006-01-02T15:04:05")}}
+		for i, value := range row[1:] {
+			name := names[i+1]
+			if name == "" {
+				continue
+			}
+			f := field{XMLName: xml.Name{Local: name}, Value: strings.TrimSpace(value)}
+			if f.Value == "" {
+				f.Null = "true"
+			}
+			fields = append(fields, f)
+		}
+		all = append(all, fields)
+	}
+	return all, nil
+}
+
+// monthFeed is a month of entries written back as a feed, to cache a month taken from a year.
+type monthFeed struct {
+	XMLName xml.Name     `xml:"feed"`
+	Entries []monthEntry `xml:"entry"`
+}
+
+type monthEntry struct {
+	Content struct {
+		Properties struct {
+			Fields []field `xml:",any"`
+		} `xml:"properties"`
+	} `xml:"content"`
+}
+
+func newMonthEntry(fields []field) monthEntry {
+	var e monthEntry
+	e.Content.Properties.Fields = fields
+	return e
+}
+
+// Backfill loads the months of a dataset from from to to in the cache, with a request per year instead of per month.
+// A year the feed does not have is read from the CSV archive when the dataset has one.
+// The months already cached are replaced, their history keeping the values they had.
+// It returns where each year came from.
+func Backfill(ctx context.Context, ds Dataset, from, to time.Time) ([]provenance.Record, error) {
+	c, err := openCache()
+	if err != nil {
+		return nil, err
+	}
+	first := monthOf(from)
+	last := monthOf(to)
+	var records []provenance.Record
+	for year := from.Year(); year <= to.Year(); year++ {
+		if err := ctx.Err(); err != nil {
+			return records, err
+		}
+		src, err := backfillYear(ctx, c, ds, year, first, last)
+		records = append(records, src)
+		if err != nil {
+			return records, err
+		}
+	}
+	return records, nil
+}
+
+// backfillYear caches the months of a year between the months first and last.
+func backfillYear(ctx context.Context, c *cache.Store, ds Dataset, year int, first, last time.Time) (provenance.Record, error) {
+	src := provenance.Record{Source: SourceName, Unit: fmt.Sprintf("%s %04d", ds, year), URL: ds.YearURL(year), Cache: provenance.CacheMiss}
+	data, err := fetchXML(ctx, &src)
+	var all [][]field
+	if err == nil {
+		all, err = entries(data)
+	}
+	if _, ok := csvPrefixes[ds]; ok && (err != nil || len(all) == 0) {
+		slog.Info("treasury year not in the feed, reading the CSV archive", "source", SourceName, "dataset", string(ds), "year", year, "error", err)
+		csvSrc := src
+		csvSrc.URL = ds.CSVURL(year)
+		data, err = fetchXML(ctx, &csvSrc)
+		if err == nil {
+			all, err = csvEntries(ds, data)
+		}
+		src = csvSrc
+		src.Note = "CSV archive"
+	}
+	if err != nil {
+		return src, fmt.Errorf("error fetching %s of %04d: %w", ds, year, err)
+	}
+	src.Hash = provenance.Hash(data)
+
+	months := make(map[time.Time][]monthEntry)
+	for _, fields := range all {
+		d, err := entryDate(fields)
+		if err != nil {
+			return src, fmt.Errorf("error reading %s of %04d: %w", ds, year, err)
+		}
+		month := time.Date(d.Year(), d.Month(), 1, 0, 0, 0, 0, time.Local)
+		if month.Before(first) || month.After(last) {
+			continue
+		}
+		months[month] = append(months[month], newMonthEntry(fields))
+	}
+	revised := 0
+	for month, entries := range months {
+		content, err := xml.Marshal(monthFeed{Entries: entries})
+		if err != nil {
+			return src, fmt.Errorf("error writing %s of %s: %w", ds, dateString(month), err)
+		}
+		monthSrc := src
+		monthSrc.Note = ""
+		previous, entry, _, err := c.Get(cacheSource, monthKey(ds, month))
+		if err != nil {
+			slog.Warn("treasury cache unreadable", "source", SourceName, "dataset", string(ds), "date", dateString(month), "error", err)
+		}
+		if err := store(c, ds, month, previous, entry.StoredAt, content, &monthSrc); err != nil {
+			return src, err
+		}
+		if monthSrc.Note != "" {
+			revised++
+		}
+	}
+	note := fmt.Sprintf("%d months", len(months))
+	if revised > 0 {
+		note += fmt.Sprintf(", %d with revised values", revised)
+	}
+	if src.Note != "" {
+		note = src.Note + ", " + note
+	}
+	src.Note = note
+	slog.Debug("treasury year backfilled", "source", SourceName, "dataset", string(ds), "year", year, "months", len(months))
+	return src, nil
+}
@@ -0,0 +1,117 @@
+package treasury
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/clauderoy790/boc-excel-file-maker/provenance"
+)
+
+// Bill is the maturity of a Treasury bill, e.g. 4WK.
+type Bill string
+
+// maturities of the bills, some only for part of the history
+const (
+	Bill4Week  Bill = "4WK"
+	Bill6Week  Bill = "6WK"
+	Bill8Week  Bill = "8WK"
+	Bill13Week Bill = "13WK"
+	Bill17Week Bill = "17WK"
+	Bill26Week Bill = "26WK"
+	Bill52Week Bill = "52WK"
+)
+
+const billDateField = "INDEX_DATE"
+
+// billField matches the rate fields of the bill rates, e.g. ROUND_B1_CLOSE_4WK_2 for the discount of 4 weeks bills
+// and ROUND_B1_YIELD_4WK_2 for their coupon equivalent.
+var billField = regexp.MustCompile(`^ROUND_B1_(CLOSE|YIELD)_(\d+WK)_2$`)
+
+// BillRecord is the bill rates of a day. Discount has the bank discount of each bill and
+// Yield its coupon equivalent, both only for the bills with a value that day.
+type BillRecord struct {
+	Date     time.Time
+	Discount map[Bill]float64
+	Yield    map[Bill]float64
+	Extra    map[string]string
+}
+
+func newBillRecord(fields []field) (*BillRecord, error) {
+	r := &BillRecord{Discount: make(map[Bill]float64), Yield: make(map[Bill]float64), Extra: make(map[string]string)}
+	date := fieldValue(fields, billDateField)
+	for _, f := range fields {
+		name := f.name()
+		m := billField.FindStringSubmatch(name)
+		switch {
+		case name == billDateField || name == idField:
+		case m != nil:
+			v, ok, err := f.number(date)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+			if m[1] == "CLOSE" {
+				r.Discount[Bill(m[2])] = v
+			} else {
+				r.Yield[Bill(m[2])] = v
+			}
+		default:
+			r.Extra[name] = strings.TrimSpace(f.Value)
+		}
+	}
+	d, err := parseDate(date)
+	if err != nil {
+		return nil, err
+	}
+	r.Date = d
+	return r, nil
+}
+
+// Bills is a month of bill rates.
+type Bills struct {
+	records map[string]*BillRecord
+	// Source tells where the month's data came from.
+	Source provenance.Record
+}
+
+func (b *Bills) setDataFromBytes(data []byte) error {
+	all, err := entries(data)
+	if err != nil {
+		return err
+	}
+	for _, fields := range all {
+		r, err := newBillRecord(fields)
+		if err != nil {
+			return err
+		}
+		b.records[dateString(r.Date)] = r
+	}
+	return nil
+}
+
+// GetRecordForDate returns the bill rates of date, formatted as YYYY-MM-DD.
+func (b *Bills) GetRecordForDate(date string) (*BillRecord, error) {
+	r := b.records[date]
+	if r == nil {
+		return nil, fmt.Errorf("no record for date %s", date)
+	}
+	return r, nil
+}
+
+// FetchBills returns the bill rates of the month of dt, from the cache when they were already fetched.
+func FetchBills(ctx context.Context, dt time.Time) (*Bills, error) {
+	b := &Bills{records: make(map[string]*BillRecord), Source: BillRates.source(dt)}
+	data, err := load(ctx, BillRates, dt, &b.Source)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.setDataFromBytes(data); err != nil {
+		return nil, fmt.Errorf("error reading %s of %s: %w", BillRates, dateString(dt), err)
+	}
+	return b, nil
+}
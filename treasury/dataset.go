@@ -0,0 +1,229 @@
+package treasury
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/clauderoy790/boc-excel-file-maker/cache"
+	"github.com/clauderoy790/boc-excel-file-maker/fetch"
+	"github.com/clauderoy790/boc-excel-file-maker/provenance"
+)
+
+const baseURL = "https://home.treasury.gov/resource-center/data-chart-center/interest-rates/pages/xml?data="
+
+// SourceName is the name used for the Treasury in provenance records.
+const SourceName = "US Treasury"
+
+// Dataset is one of the interest rate datasets served by the Treasury, named as in its URLs.
+type Dataset string
+
+const (
+	YieldCurve     Dataset = "daily_treasury_yield_curve"
+	RealYieldCurve Dataset = "daily_treasury_real_yield_curve"
+	BillRates      Dataset = "daily_treasury_bill_rates"
+	LongTermRate   Dataset = "daily_treasury_long_term_rate"
+	RealLongTerm   Dataset = "daily_treasury_real_long_term"
+)
+
+// URL returns the address of the data of the month of d.
+func (ds Dataset) URL(d time.Time) string {
+	return fmt.Sprintf("%s%s&field_tdr_date_value_month=%04d%02d", baseURL, ds, d.Year(), int(d.Month()))
+}
+
+// TextURL returns the address of the page showing the dataset.
+func (ds Dataset) TextURL() string {
+	return "https://home.treasury.gov/resource-center/data-chart-center/interest-rates/TextView?type=" + string(ds)
+}
+
+func (ds Dataset) source(d time.Time) provenance.Record {
+	return provenance.Record{
+		Source: SourceName,
+		Unit:   fmt.Sprintf("%s %04d-%02d", ds, d.Year(), int(d.Month())),
+		URL:    ds.URL(d),
+	}
+}
+
+// cacheSource is the source of the Treasury data in the cache.
+const cacheSource = "treasury"
+
+// cacheKey is the key of a file of a dataset in the cache.
+func cacheKey(ds Dataset, name string) string {
+	return string(ds) + "/" + name
+}
+
+// monthOf returns the first day of the month of dt, which the months are cached under.
+func monthOf(dt time.Time) time.Time {
+	return time.Date(dt.Year(), dt.Month(), 1, 0, 0, 0, 0, dt.Location())
+}
+
+func monthKey(ds Dataset, dt time.Time) string {
+	return cacheKey(ds, dateString(dt)+".xml")
+}
+
+// load returns the XML of the month of dt, from the cache unless it has expired.
+// src tells where it came from. Every fetch is added to the history of the month,
+// and a cached month that cannot be fetched again is still used.
+func load(ctx context.Context, ds Dataset, dt time.Time, src *provenance.Record) ([]byte, error) {
+	dt = monthOf(dt)
+	c, err := openCache()
+	if err != nil {
+		return nil, err
+	}
+	key := monthKey(ds, dt)
+	cached, entry, ok, err := c.Get(cacheSource, key)
+	if err != nil {
+		// a damaged month is fetched again
+		slog.Warn("treasury cache unreadable", "source", SourceName, "dataset", string(ds), "date", dateString(dt), "error", err)
+		cached, ok = nil, false
+	}
+	if ok && !expired(dt, entry.StoredAt, time.Now()) {
+		src.Cache = provenance.CacheHit
+		src.FetchedAt = entry.StoredAt
+		src.Hash = provenance.Hash(cached)
+		slog.Debug("treasury cache hit", "source", SourceName, "dataset", string(ds), "date", dateString(dt), "key", key)
+		return cached, nil
+	}
+
+	src.Cache = provenance.CacheMiss
+	data, err := fetchXML(ctx, src)
+	if err != nil {
+		if cached == nil {
+			return nil, fmt.Errorf("error fetching data: %w", err)
+		}
+		slog.Warn("treasury refresh failed, using the cache", "source", SourceName, "dataset", string(ds), "date", dateString(dt), "error", err)
+		src.Cache = provenance.CacheHit
+		src.FetchedAt = entry.StoredAt
+		src.Status = 0
+		src.Hash = provenance.Hash(cached)
+		src.Note = "refresh failed: " + err.Error()
+		return cached, nil
+	}
+	if err := store(c, ds, dt, cached, entry.StoredAt, data, src); err != nil {
+		return nil, err
+	}
+	slog.Debug("treasury fetched", "source", SourceName, "dataset", string(ds), "date", dateString(dt), "status", src.Status)
+	src.Hash = provenance.Hash(data)
+	return data, nil
+}
+
+// store caches the data of the month of dt fetched as told by src, adding it to the history of the month.
+// previous is the data cached before, seen at previousAt, nil when there was none.
+func store(c *cache.Store, ds Dataset, dt time.Time, previous []byte, previousAt time.Time, data []byte, src *provenance.Record) error {
+	seenAt := src.FetchedAt
+	if seenAt.IsZero() {
+		seenAt = time.Now()
+	}
+	revisions, err := updateHistory(c, ds, dt, previous, previousAt, data, seenAt)
+	if err != nil {
+		return err
+	}
+	if len(revisions) > 0 {
+		src.Note = fmt.Sprintf("%d revised values", len(revisions))
+		slog.Info("treasury revisions", "source", SourceName, "dataset", string(ds), "date", dateString(dt), "count", len(revisions))
+	}
+	if _, err := c.Put(cacheSource, monthKey(ds, dt), data); err != nil {
+		return fmt.Errorf("error caching %s of %s: %w", ds, dateString(dt), err)
+	}
+	return nil
+}
+
+func fetchXML(ctx context.Context, src *provenance.Record) ([]byte, error) {
+	resp, err := fetch.Get(ctx, src.URL)
+	if resp != nil {
+		src.FetchedAt = resp.FetchedAt
+		src.Status = resp.Status
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching %s: %w", src.URL, err)
+	}
+	return resp.Body, nil
+}
+
+// feed is the Atom feed of the Treasury. The fields of an entry vary with the dataset and the period,
+// so they are read as a list and sorted out by each dataset.
+type feed struct {
+	Entries []struct {
+		Properties struct {
+			Fields []field `xml:",any"`
+		} `xml:"content>properties"`
+	} `xml:"entry"`
+}
+
+type field struct {
+	XMLName xml.Name
+	Null    string `xml:"http://schemas.microsoft.com/ado/2007/08/dataservices/metadata null,attr,omitempty"`
+	Value   string `xml:",chardata"`
+}
+
+func (f field) name() string {
+	return f.XMLName.Local
+}
+
+// number returns the value of the field and whether it has one, null and blank fields have none.
+func (f field) number(date string) (float64, bool, error) {
+	value := strings.TrimSpace(f.Value)
+	if f.Null == "true" || value == "" {
+		return 0, false, nil
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, false, &InvalidValueError{Date: date, Field: f.name(), Value: value, Err: err}
+	}
+	return v, true, nil
+}
+
+// entries returns the fields of each entry of a feed.
+func entries(data []byte) ([][]field, error) {
+	var fd feed
+	if err := xml.Unmarshal(data, &fd); err != nil {
+		return nil, fmt.Errorf("error while unmarshalling: %w", err)
+	}
+	var all [][]field
+	for _, e := range fd.Entries {
+		all = append(all, e.Properties.Fields)
+	}
+	return all, nil
+}
+
+// fieldValue returns the value of a field of an entry, empty when it has none.
+func fieldValue(fields []field, name string) string {
+	for _, f := range fields {
+		if f.name() == name {
+			return strings.TrimSpace(f.Value)
+		}
+	}
+	return ""
+}
+
+func parseDate(date string) (time.Time, error) {
+	d, err := time.Parse("2006-01-02T15:04:05", date)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date format: %q", date)
+	}
+	return d, nil
+}
+
+// InvalidValueError is a value of the Treasury data that is not a number.
+type InvalidValueError struct {
+	Date  string
+	Field string
+	Value string
+	Err   error
+}
+
+func (e *InvalidValueError) Error() string {
+	return fmt.Sprintf("treasury %s: invalid %s value %q: %v", e.Date, e.Field, e.Value, e.Err)
+}
+
+func (e *InvalidValueError) Unwrap() error {
+	return e.Err
+}
+
+func dateString(dt time.Time) string {
+	return fmt.Sprintf("%04d-%02d-%02d", dt.Year(), int(dt.Month()), dt.Day())
+}
@@ -0,0 +1,267 @@
+package treasury
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/clauderoy790/boc-excel-file-maker/cache"
+)
+
+// MaxAge is how long any cached month is used before being fetched again. When it is 0, the
+// RecentMonths last months are fetched again after RecentMaxAge and the older ones are kept.
+// The values of a month fetched again are compared to those already seen to find the revisions.
+var MaxAge time.Duration
+
+// RecentMonths is the number of months, the current one included, still being published or
+// revised by the Treasury.
+var RecentMonths = 3
+
+// RecentMaxAge is how long a cached recent month is used before being fetched again when MaxAge is 0.
+var RecentMaxAge = time.Hour
+
+// expired tells if the month of dt, cached at storedAt, must be fetched again at now.
+func expired(dt, storedAt, now time.Time) bool {
+	if MaxAge > 0 {
+		return now.Sub(storedAt) >= MaxAge
+	}
+	months := (now.Year()-dt.Year())*12 + int(now.Month()) - int(dt.Month())
+	return months < RecentMonths && now.Sub(storedAt) >= RecentMaxAge
+}
+
+// Datasets are all the datasets of the Treasury.
+var Datasets = []Dataset{YieldCurve, RealYieldCurve, BillRates, LongTermRate, RealLongTerm}
+
+// revisionsFile is the log of the revisions of a dataset in the cache, one JSON revision per line.
+const revisionsFile = "revisions.jsonl"
+
+// Version is a value of an observation and when it was first seen, empty when it was removed.
+type Version struct {
+	Value  string    `json:"value"`
+	SeenAt time.Time `json:"seenAt"`
+}
+
+// Revision is a published value that changed between two fetches.
+type Revision struct {
+	Dataset Dataset `json:"dataset"`
+	// Date is the day of the observation, as YYYY-MM-DD.
+	Date string `json:"date"`
+	// Field is the field of the entry, preceded by its rate type for the long-term rates, e.g. BC_20year/RATE.
+	Field     string    `json:"field"`
+	Old       string    `json:"old"`
+	New       string    `json:"new"`
+	OldSeenAt time.Time `json:"oldSeenAt"`
+	SeenAt    time.Time `json:"seenAt"`
+}
+
+func (r Revision) String() string {
+	return fmt.Sprintf("%s %s %s: %q (%s) -> %q (%s)", r.Dataset, r.Date, r.Field,
+		r.Old, r.OldSeenAt.Format(time.RFC3339), r.New, r.SeenAt.Format(time.RFC3339))
+}
+
+// history is every version of the observations of a month, by day and field.
+type history map[string]map[string][]Version
+
+func historyKey(ds Dataset, dt time.Time) string {
+	return cacheKey(ds, dateString(dt)+".history.json")
+}
+
+func readHistory(c *cache.Store, key string) (history, error) {
+	h := make(history)
+	data, _, ok, err := c.Get(cacheSource, key)
+	if err != nil {
+		return nil, fmt.Errorf("error reading history %s: %w", key, err)
+	}
+	if !ok {
+		return h, nil
+	}
+	if err := json.Unmarshal(data, &h); err != nil {
+		return nil, fmt.Errorf("error reading history %s: %w", key, err)
+	}
+	return h, nil
+}
+
+// observations returns the values of the entries of a feed by day and field.
+func observations(data []byte) (map[string]map[string]string, error) {
+	all, err := entries(data)
+	if err != nil {
+		return nil, err
+	}
+	obs := make(map[string]map[string]string)
+	for _, fields := range all {
+		d, err := entryDate(fields)
+		if err != nil {
+			return nil, err
+		}
+		day := obs[dateString(d)]
+		if day == nil {
+			day = make(map[string]string)
+			obs[dateString(d)] = day
+		}
+		// the long-term datasets have an entry by rate type each day
+		prefix := ""
+		if rateType := fieldValue(fields, rateTypeField); rateType != "" {
+			prefix = rateType + "/"
+		}
+		for _, f := range fields {
+			switch f.name() {
+			case idField, dateField, billDateField, quoteDateField, rateTypeField:
+				continue
+			}
+			value := strings.TrimSpace(f.Value)
+			if f.Null == "true" {
+				value = ""
+			}
+			day[prefix+f.name()] = value
+		}
+	}
+	return obs, nil
+}
+
+// entryDate returns the day of an entry, whose date field depends on the dataset.
+func entryDate(fields []field) (time.Time, error) {
+	date := ""
+	for _, name := range []string{dateField, billDateField, quoteDateField} {
+		if date = fieldValue(fields, name); date != "" {
+			break
+		}
+	}
+	return parseDate(date)
+}
+
+// add adds the values of a fetch to the history and returns those that revise the last version seen.
+// A field missing from a day that is still published is a value removed.
+func (h history) add(ds Dataset, obs map[string]map[string]string, seenAt time.Time) []Revision {
+	var revisions []Revision
+	for date, fields := range obs {
+		day := h[date]
+		if day == nil {
+			day = make(map[string][]Version)
+			h[date] = day
+		}
+		for field, versions := range day {
+			if _, ok := fields[field]; !ok && versions[len(versions)-1].Value != "" {
+				fields[field] = ""
+			}
+		}
+		for field, value := range fields {
+			versions := day[field]
+			if len(versions) > 0 {
+				last := versions[len(versions)-1]
+				if last.Value == value {
+					continue
+				}
+				revisions = append(revisions, Revision{Dataset: ds, Date: date, Field: field,
+					Old: last.Value, New: value, OldSeenAt: last.SeenAt, SeenAt: seenAt})
+			}
+			day[field] = append(versions, Version{Value: value, SeenAt: seenAt})
+		}
+	}
+	sort.Slice(revisions, func(i, j int) bool {
+		if revisions[i].Date != revisions[j].Date {
+			return revisions[i].Date < revisions[j].Date
+		}
+		return revisions[i].Field < revisions[j].Field
+	})
+	return revisions
+}
+
+// updateHistory adds a fetch of the month of dt to its history in the cache, and logs the revisions.
+// previous is the data cached before the fetch, seen at previousAt, used when there is no history yet.
+func updateHistory(c *cache.Store, ds Dataset, dt time.Time, previous []byte, previousAt time.Time, data []byte, seenAt time.Time) ([]Revision, error) {
+	key := historyKey(ds, dt)
+	h, err := readHistory(c, key)
+	if err != nil {
+		return nil, err
+	}
+	if len(h) == 0 && previous != nil {
+		obs, err := observations(previous)
+		if err != nil {
+			return nil, fmt.Errorf("error reading the cached %s of %s: %w", ds, dateString(dt), err)
+		}
+		h.add(ds, obs, previousAt)
+	}
+	obs, err := observations(data)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s of %s: %w", ds, dateString(dt), err)
+	}
+	revisions := h.add(ds, obs, seenAt)
+
+	content, err := json.Marshal(h)
+	if err != nil {
+		return nil, fmt.Errorf("error writing history %s: %w", key, err)
+	}
+	// the history and the log cannot be fetched again, they are kept when the cache is purged
+	if _, err := c.Keep(cacheSource, key, content); err != nil {
+		return nil, fmt.Errorf("error writing history %s: %w", key, err)
+	}
+	if len(revisions) == 0 {
+		return nil, nil
+	}
+	// the log is rewritten whole, so it is never left half written
+	logKey := cacheKey(ds, revisionsFile)
+	log, _, _, err := c.Get(cacheSource, logKey)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the revisions of %s: %w", ds, err)
+	}
+	buf := bytes.NewBuffer(log)
+	enc := json.NewEncoder(buf)
+	for _, r := range revisions {
+		if err := enc.Encode(r); err != nil {
+			return nil, fmt.Errorf("error logging the revisions of %s: %w", ds, err)
+		}
+	}
+	if _, err := c.Keep(cacheSource, logKey, buf.Bytes()); err != nil {
+		return nil, fmt.Errorf("error logging the revisions of %s: %w", ds, err)
+	}
+	return revisions, nil
+}
+
+// Revisions returns the revisions found in the data of a dataset, in the order they were found.
+func Revisions(ds Dataset) ([]Revision, error) {
+	c, err := openCache()
+	if err != nil {
+		return nil, err
+	}
+	return readRevisions(c, ds)
+}
+
+func readRevisions(c *cache.Store, ds Dataset) ([]Revision, error) {
+	data, _, ok, err := c.Get(cacheSource, cacheKey(ds, revisionsFile))
+	if err != nil || !ok {
+		return nil, err
+	}
+	var revisions []Revision
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var r Revision
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return nil, fmt.Errorf("error reading the revisions of %s: %w", ds, err)
+		}
+		revisions = append(revisions, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading the revisions of %s: %w", ds, err)
+	}
+	return revisions, nil
+}
+
+// History returns every version seen of the fields of a day of a dataset, none when its month was never fetched.
+func History(ds Dataset, date time.Time) (map[string][]Version, error) {
+	c, err := openCache()
+	if err != nil {
+		return nil, err
+	}
+	h, err := readHistory(c, historyKey(ds, monthOf(date)))
+	if err != nil {
+		return nil, err
+	}
+	return h[dateString(date)], nil
+}
@@ -0,0 +1,214 @@
+package treasury
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/clauderoy790/boc-excel-file-maker/cache"
+)
+
+// legacyDir is the folder of the cache of the older versions, next to the executable,
+// with a folder by dataset of plain files.
+const legacyDir = "cache"
+
+// importedSuffix is added to the name of the legacy folder once it is imported.
+const importedSuffix = ".imported"
+
+var importOnce sync.Once
+
+// openCache returns the default cache. The first call imports the legacy folder in it.
+func openCache() (*cache.Store, error) {
+	c, err := cache.Default()
+	if err != nil {
+		return nil, err
+	}
+	importOnce.Do(func() {
+		ex, err := os.Executable()
+		if err != nil {
+			return
+		}
+		if err := importLegacy(c, filepath.Join(filepath.Dir(ex), legacyDir)); err != nil {
+			slog.Warn("treasury legacy cache not imported", "source", SourceName, "error", err)
+		}
+	})
+	return c, nil
+}
+
+// importLegacy copies the months, histories and revisions of the datasets in dir, the cache
+// of the older versions, to c, leaving the entries c already has. The months of the yield
+// curve the first versions kept as JSON directly in dir are imported too. dir is then renamed
+// with importedSuffix, so it is imported once and can be deleted by hand, and the files left
+// behind are logged.
+func importLegacy(c *cache.Store, dir string) error {
+	if _, err := os.Stat(dir); err != nil {
+		return nil
+	}
+	// the store itself may have been put there with -cache-dir
+	if abs, err := filepath.Abs(dir); err == nil {
+		if store, err := filepath.Abs(c.Dir()); err == nil && store == abs {
+			return nil
+		}
+	}
+	count, skipped, err := importJSON(c, dir)
+	if err != nil {
+		return err
+	}
+	for _, ds := range Datasets {
+		files, err := os.ReadDir(filepath.Join(dir, string(ds)))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("error reading legacy cache %s: %w", dir, err)
+		}
+		for _, f := range files {
+			name := f.Name()
+			kept := name == revisionsFile || strings.HasSuffix(name, ".history.json")
+			if f.IsDir() || !kept && !strings.HasSuffix(name, ".xml") {
+				skipped = append(skipped, filepath.Join(string(ds), name))
+				continue
+			}
+			key := cacheKey(ds, name)
+			if _, _, ok, _ := c.Get(cacheSource, key); ok {
+				continue
+			}
+			data, err := os.ReadFile(filepath.Join(dir, string(ds), name))
+			if err != nil {
+				return fmt.Errorf("error reading legacy cache %s: %w", dir, err)
+			}
+			put := c.Put
+			if kept {
+				put = c.Keep
+			}
+			if _, err := put(cacheSource, key, data); err != nil {
+				return fmt.Errorf("error importing %s: %w", key, err)
+			}
+			count++
+		}
+	}
+	if err := os.Rename(dir, dir+importedSuffix); err != nil {
+		return fmt.Errorf("error renaming legacy cache %s: %w", dir, err)
+	}
+	slog.Info("treasury legacy cache imported", "source", SourceName, "from", dir, "entries", count, "renamed", dir+importedSuffix)
+	if len(skipped) > 0 {
+		slog.Warn("treasury legacy files not imported", "source", SourceName, "folder", dir+importedSuffix, "files", strings.Join(skipped, ", "))
+	}
+	return nil
+}
+
+// importJSON imports the months of the yield curve of the first versions, the feed converted
+// to JSON in a file named by a day of the month. The last day of a month is the most complete.
+// It returns the number of months imported and the files of dir it did not import.
+func importJSON(c *cache.Store, dir string) (int, []string, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, nil, fmt.Errorf("error reading legacy cache %s: %w", dir, err)
+	}
+	months := make(map[string]string)
+	var skipped []string
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		name := f.Name()
+		day, err := time.Parse("2006-01-02.json", name)
+		if err != nil {
+			skipped = append(skipped, name)
+			continue
+		}
+		key := monthKey(YieldCurve, monthOf(day))
+		if last, ok := months[key]; ok {
+			skipped = append(skipped, last)
+		}
+		months[key] = name
+	}
+	keys := make([]string, 0, len(months))
+	for key := range months {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	count := 0
+	for _, key := range keys {
+		name := months[key]
+		if _, _, ok, _ := c.Get(cacheSource, key); ok {
+			skipped = append(skipped, name)
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return 0, nil, fmt.Errorf("error reading legacy cache %s: %w", dir, err)
+		}
+		content, err := legacyFeed(data)
+		if err != nil {
+			slog.Warn("treasury legacy month unreadable", "source", SourceName, "file", name, "error", err)
+			skipped = append(skipped, name)
+			continue
+		}
+		if _, err := c.Put(cacheSource, key, content); err != nil {
+			return 0, nil, fmt.Errorf("error importing %s: %w", key, err)
+		}
+		count++
+	}
+	sort.Strings(skipped)
+	return count, skipped, nil
+}
+
+// legacyValue is a field of the JSON of the first versions, the attributes prefixed with a dash.
+type legacyValue struct {
+	Content string `json:"#content"`
+	Null    string `json:"-null"`
+}
+
+// legacyFeed writes the JSON of a month of the first versions back as the feed it was converted from.
+func legacyFeed(data []byte) ([]byte, error) {
+	var doc struct {
+		Feed struct {
+			Entry json.RawMessage `json:"entry"`
+		} `json:"feed"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("error while unmarshalling: %w", err)
+	}
+	type legacyEntry struct {
+		Content struct {
+			Properties map[string]legacyValue `json:"properties"`
+		} `json:"content"`
+	}
+	var all []legacyEntry
+	// a feed of a single entry was converted to an object instead of a list
+	if err := json.Unmarshal(doc.Feed.Entry, &all); err != nil {
+		var one legacyEntry
+		if err := json.Unmarshal(doc.Feed.Entry, &one); err != nil {
+			return nil, fmt.Errorf("error while unmarshalling: %w", err)
+		}
+		all = []legacyEntry{one}
+	}
+	var entries []monthEntry
+	for _, e := range all {
+		names := make([]string, 0, len(e.Content.Properties))
+		for name := range e.Content.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fields := make([]field, 0, len(names))
+		for _, name := range names {
+			v := e.Content.Properties[name]
+			fields = append(fields, field{XMLName: xml.Name{Local: name}, Null: v.Null, Value: v.Content})
+		}
+		if fieldValue(fields, "NEW_DATE") == "" {
+			return nil, errors.New("entry without a date")
+		}
+		entries = append(entries, newMonthEntry(fields))
+	}
+	return xml.Marshal(monthFeed{Entries: entries})
+}
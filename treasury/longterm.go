@@ -0,0 +1,178 @@
+package treasury
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/clauderoy790/boc-excel-file-maker/provenance"
+)
+
+// RateType is the kind of a long-term rate, as in the RATE_TYPE field.
+type RateType string
+
+const (
+	// LongTermComposite is the average of the bonds maturing in more than 10 years.
+	LongTermComposite RateType = "Over_10_Years"
+	// LongTerm20Year is the 20 years constant maturity rate.
+	LongTerm20Year RateType = "BC_20year"
+)
+
+// fields of the long-term datasets
+const (
+	quoteDateField = "QUOTE_DATE"
+	rateTypeField  = "RATE_TYPE"
+	rateField      = "RATE"
+	factorField    = "EXTRAPOLATION_FACTOR"
+)
+
+// LongTermRecord is the long-term rates of a day, the dataset has an entry for each rate type.
+// Rates only has the types with a value that day, ExtrapolationFactor is only set when published.
+type LongTermRecord struct {
+	Date                time.Time
+	Rates               map[RateType]float64
+	ExtrapolationFactor *float64
+	Extra               map[string]string
+}
+
+// RealLongTermRecord is the real long-term average rate of a day.
+type RealLongTermRecord struct {
+	Date  time.Time
+	Rate  float64
+	Extra map[string]string
+}
+
+// LongTerm is a month of long-term rates.
+type LongTerm struct {
+	records map[string]*LongTermRecord
+	// Source tells where the month's data came from.
+	Source provenance.Record
+}
+
+func (l *LongTerm) setDataFromBytes(data []byte) error {
+	all, err := entries(data)
+	if err != nil {
+		return err
+	}
+	for _, fields := range all {
+		date := fieldValue(fields, quoteDateField)
+		d, err := parseDate(date)
+		if err != nil {
+			return err
+		}
+		r := l.records[dateString(d)]
+		if r == nil {
+			r = &LongTermRecord{Date: d, Rates: make(map[RateType]float64), Extra: make(map[string]string)}
+			l.records[dateString(d)] = r
+		}
+		rateType := RateType(strings.TrimSpace(fieldValue(fields, rateTypeField)))
+		for _, f := range fields {
+			switch f.name() {
+			case quoteDateField, rateTypeField, idField:
+			case rateField:
+				v, ok, err := f.number(date)
+				if err != nil {
+					return err
+				}
+				if ok {
+					r.Rates[rateType] = v
+				}
+			case factorField:
+				v, ok, err := f.number(date)
+				if err != nil {
+					return err
+				}
+				if ok {
+					r.ExtrapolationFactor = &v
+				}
+			default:
+				r.Extra[f.name()] = strings.TrimSpace(f.Value)
+			}
+		}
+	}
+	return nil
+}
+
+// GetRecordForDate returns the long-term rates of date, formatted as YYYY-MM-DD.
+func (l *LongTerm) GetRecordForDate(date string) (*LongTermRecord, error) {
+	r := l.records[date]
+	if r == nil {
+		return nil, fmt.Errorf("no record for date %s", date)
+	}
+	return r, nil
+}
+
+// FetchLongTerm returns the long-term rates of the month of dt, from the cache when they were already fetched.
+func FetchLongTerm(ctx context.Context, dt time.Time) (*LongTerm, error) {
+	l := &LongTerm{records: make(map[string]*LongTermRecord), Source: LongTermRate.source(dt)}
+	data, err := load(ctx, LongTermRate, dt, &l.Source)
+	if err != nil {
+		return nil, err
+	}
+	if err := l.setDataFromBytes(data); err != nil {
+		return nil, fmt.Errorf("error reading %s of %s: %w", LongTermRate, dateString(dt), err)
+	}
+	return l, nil
+}
+
+// RealLongTermRates is a month of real long-term rates.
+type RealLongTermRates struct {
+	records map[string]*RealLongTermRecord
+	// Source tells where the month's data came from.
+	Source provenance.Record
+}
+
+func (l *RealLongTermRates) setDataFromBytes(data []byte) error {
+	all, err := entries(data)
+	if err != nil {
+		return err
+	}
+	for _, fields := range all {
+		date := fieldValue(fields, quoteDateField)
+		d, err := parseDate(date)
+		if err != nil {
+			return err
+		}
+		r := &RealLongTermRecord{Date: d, Extra: make(map[string]string)}
+		hasRate := false
+		for _, f := range fields {
+			switch f.name() {
+			case quoteDateField, idField:
+			case rateField:
+				if r.Rate, hasRate, err = f.number(date); err != nil {
+					return err
+				}
+			default:
+				r.Extra[f.name()] = strings.TrimSpace(f.Value)
+			}
+		}
+		// a day without a rate has no record, like a day missing from the dataset
+		if hasRate {
+			l.records[dateString(d)] = r
+		}
+	}
+	return nil
+}
+
+// GetRecordForDate returns the real long-term rate of date, formatted as YYYY-MM-DD.
+func (l *RealLongTermRates) GetRecordForDate(date string) (*RealLongTermRecord, error) {
+	r := l.records[date]
+	if r == nil {
+		return nil, fmt.Errorf("no record for date %s", date)
+	}
+	return r, nil
+}
+
+// FetchRealLongTerm returns the real long-term rates of the month of dt, from the cache when they were already fetched.
+func FetchRealLongTerm(ctx context.Context, dt time.Time) (*RealLongTermRates, error) {
+	l := &RealLongTermRates{records: make(map[string]*RealLongTermRecord), Source: RealLongTerm.source(dt)}
+	data, err := load(ctx, RealLongTerm, dt, &l.Source)
+	if err != nil {
+		return nil, err
+	}
+	if err := l.setDataFromBytes(data); err != nil {
+		return nil, fmt.Errorf("error reading %s of %s: %w", RealLongTerm, dateString(dt), err)
+	}
+	return l, nil
+}
@@ -1,181 +1,199 @@
 package treasury
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
-	"io/ioutil"
-	"net/http"
-	"os"
-	"path"
-	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
-	xj "github.com/basgys/goxml2json"
 	"github.com/clauderoy790/boc-excel-file-maker/common"
+	"github.com/clauderoy790/boc-excel-file-maker/provenance"
 )
 
-const downloadPath = "https://home.treasury.gov/resource-center/data-chart-center/interest-rates/pages/xml?data=daily_treasury_yield_curve&field_tdr_date_value_month="
-const cachePath = "./cache"
+// Tenor is the name of a maturity in a yield curve of the Treasury, e.g. BC_10YEAR.
+type Tenor string
+
+// tenors published by the Treasury, some only for part of the history
+const (
+	Month1     Tenor = "BC_1MONTH"
+	Month1_5   Tenor = "BC_1_5MONTH"
+	Month2     Tenor = "BC_2MONTH"
+	Month3     Tenor = "BC_3MONTH"
+	Month4     Tenor = "BC_4MONTH"
+	Month6     Tenor = "BC_6MONTH"
+	Year1      Tenor = "BC_1YEAR"
+	Year2      Tenor = "BC_2YEAR"
+	Year3      Tenor = "BC_3YEAR"
+	Year5      Tenor = "BC_5YEAR"
+	Year7      Tenor = "BC_7YEAR"
+	Year10     Tenor = "BC_10YEAR"
+	Year20     Tenor = "BC_20YEAR"
+	Year30     Tenor = "BC_30YEAR"
+	Year30Disp Tenor = "BC_30YEARDISPLAY"
+)
 
-func newTreasury(d time.Time) *Treasury {
-	path := fmt.Sprintf("%s%02d%02d", downloadPath, d.Year(), int(d.Month()))
-	return &Treasury{
-		path:  path,
-		props: make(map[string]*Properties),
-	}
-}
+// tenors of the real yield curve
+const (
+	Real5Year  Tenor = "TC_5YEAR"
+	Real7Year  Tenor = "TC_7YEAR"
+	Real10Year Tenor = "TC_10YEAR"
+	Real20Year Tenor = "TC_20YEAR"
+	Real30Year Tenor = "TC_30YEAR"
+)
 
-type Treasury struct {
-	path  string
-	data  *TreasuryData
-	props map[string]*Properties
-}
+// tenorPrefixes start the names of the tenors of the nominal and real yield curves
+var tenorPrefixes = []string{"BC_", "TC_"}
 
-func (t *Treasury) fetchData() ([]byte, error) {
-	var resp *http.Response
-	var err error
-	if resp, err = http.Get(t.path); err != nil {
-		return nil, fmt.Errorf("error sending request: %w", err)
-	}
-	bytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("error reading body: %w", err)
-	}
-	bodyStr := string(bytes)
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("got error status code: %d with response: %s", resp.StatusCode, bodyStr)
-	}
-	jsonData, err := xj.Convert(strings.NewReader(bodyStr))
-	if err != nil {
-		return nil, fmt.Errorf("fail to convert XML to json: %w", err)
-	}
-	return jsonData.Bytes(), nil
+// tenors the Treasury does not publish, computed as the average of their neighbours
+const (
+	Year4 Tenor = "BC_4YEAR"
+	Year6 Tenor = "BC_6YEAR"
+	Year8 Tenor = "BC_8YEAR"
+)
 
+var derivedTenors = []struct {
+	tenor, low, high Tenor
+}{
+	{Year4, Year3, Year5},
+	{Year6, Year5, Year7},
+	{Year8, Year7, Year10},
 }
 
-func (t *Treasury) setDataFromBytes(jsonBytes []byte) error {
-	treasury := new(TreasuryData)
-	if err := json.Unmarshal(jsonBytes, treasury); err != nil {
-		return fmt.Errorf("error while unmarshalling: %w", err)
-	}
+// fields of an entry that are neither a tenor nor unknown
+const (
+	idField   = "Id"
+	dateField = "NEW_DATE"
+)
 
-	// remove unless text in date
-	t.data = treasury
-	for _, entry := range treasury.Feed.Entry {
-		prop := entry.Content.Properties
-		dateSuffix := "T00:00:00"
-		if !strings.HasSuffix(prop.Date.Content, dateSuffix) {
-			return fmt.Errorf("invalid date format: %s", prop.Date.Content)
-		}
-		date := strings.ReplaceAll(prop.Date.Content, dateSuffix, "")
-		prop.Date.Content = date
+// Record is the data of a day. Tenors only has the tenors with a value that day,
+// Extra keeps the fields the parser does not know about.
+type Record struct {
+	Date   time.Time
+	Tenors map[Tenor]float64
+	Extra  map[string]string
+}
 
-		//custom vals
-		prop.Bc4Year.Content = t.getAverageValue(prop.Bc3Year, prop.Bc5Year)
-		prop.Bc6Year.Content = t.getAverageValue(prop.Bc5Year, prop.Bc7Year)
-		prop.Bc8Year.Content = t.getAverageValue(prop.Bc7Year, prop.Bc10Year)
+// Value returns the value of a tenor and whether it had one that day.
+func (r *Record) Value(tenor Tenor) (float64, bool) {
+	v, ok := r.Tenors[tenor]
+	return v, ok
+}
 
-		t.props[date] = prop
+func newTreasury(ds Dataset, d time.Time) *Treasury {
+	return &Treasury{
+		dataset: ds,
+		records: make(map[string]*Record),
+		Source:  ds.source(d),
 	}
-	return nil
 }
 
-func (t *Treasury) getAverageValue(v1, v2 V) string {
-	f1, err := strconv.ParseFloat(v1.Content, 64)
+// Treasury is a month of a yield curve, nominal or real.
+type Treasury struct {
+	dataset Dataset
+	records map[string]*Record
+	// Source tells where the month's data came from.
+	Source provenance.Record
+}
+
+func (t *Treasury) setDataFromBytes(data []byte) error {
+	all, err := entries(data)
 	if err != nil {
-		panic(err)
+		return err
 	}
-	f2, err := strconv.ParseFloat(v2.Content, 64)
-	if err != nil {
-		panic(err)
+	for _, fields := range all {
+		r, err := newRecord(fields)
+		if err != nil {
+			return err
+		}
+		t.records[dateString(r.Date)] = r
 	}
-	f := common.Average(f1, f2)
-
-	return fmt.Sprintf("%.2f", f)
+	return nil
 }
 
-func (t *Treasury) GetPropsForDate(date string) (*Properties, error) {
-	props := t.props[date]
-	if props == nil {
-		return nil, fmt.Errorf("no properties for date %s", date)
+func newRecord(fields []field) (*Record, error) {
+	r := &Record{Tenors: make(map[Tenor]float64), Extra: make(map[string]string)}
+	date := fieldValue(fields, dateField)
+	for _, f := range fields {
+		name := f.name()
+		switch {
+		case name == dateField || name == idField:
+		case isTenor(name):
+			v, ok, err := f.number(date)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				r.Tenors[Tenor(name)] = v
+			}
+		default:
+			r.Extra[name] = strings.TrimSpace(f.Value)
+		}
 	}
-	return props, nil
-}
-
-func FetchData(dt time.Time) (*Treasury, error) {
-	t := newTreasury(dt)
-	ex, err := os.Executable()
+	d, err := parseDate(date)
 	if err != nil {
-		return nil, fmt.Errorf("unable to get executable path: %w", err)
-	}
-	wd := filepath.Dir(ex)
-	cache := path.Join(wd, cachePath)
-	if _, err := os.Stat(cache); os.IsNotExist(err) {
-		os.Mkdir(cache, 0755)
+		return nil, err
 	}
-	jsonFile := path.Join(cache, dateString(dt)+".json")
-	var data []byte
-	if _, err := os.Stat(jsonFile); err == nil {
-		data, err = ioutil.ReadFile(jsonFile)
-		if err != nil {
-			return nil, fmt.Errorf("error restoring cache file %s: %w", jsonFile, err)
+	r.Date = d
+	for _, dt := range derivedTenors {
+		if _, ok := r.Tenors[dt.tenor]; ok {
+			continue
 		}
-	} else {
-		data, err = t.fetchData()
-		if err != nil {
-			return nil, fmt.Errorf("error fetching data: %w", err)
-		}
-		if err := ioutil.WriteFile(jsonFile, data, 0755); err != nil {
-			return nil, fmt.Errorf("error writing cached file %s: %w", jsonFile, err)
+		if avg, ok := averageValue(r, dt.low, dt.high); ok {
+			r.Tenors[dt.tenor] = avg
 		}
 	}
-	t.setDataFromBytes(data)
-	return t, nil
+	return r, nil
 }
 
-type TreasuryData struct {
-	Feed Feed `json:"feed"`
+func isTenor(name string) bool {
+	for _, prefix := range tenorPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
 }
 
-type V struct {
-	Content string `json:"#content"`
-	Type    string `json:"-type"`
+// averageValue is the average of two tenors rounded to 2 decimals like the published values,
+// it has none when one of them is missing.
+func averageValue(r *Record, low, high Tenor) (float64, bool) {
+	v1, ok1 := r.Value(low)
+	v2, ok2 := r.Value(high)
+	if !ok1 || !ok2 {
+		return 0, false
+	}
+	avg, _ := strconv.ParseFloat(fmt.Sprintf("%.2f", common.Average(v1, v2)), 64)
+	return avg, true
 }
 
-type Properties struct {
-	Date            V `json:"NEW_DATE"`
-	Bc2Year         V `json:"BC_2YEAR"`
-	Bc3Month        V `json:"BC_3MONTH"`
-	Bc30YearDisplay V `json:"BC_30YEARDISPLAY"`
-	Bc2Month        V `json:"BC_2MONTH"`
-	Bc5Year         V `json:"BC_5YEAR"`
-	Bc3Year         V `json:"BC_3YEAR"`
-	Bc7Year         V `json:"BC_7YEAR"`
-	Bc10Year        V `json:"BC_10YEAR"`
-	Bc6Month        V `json:"BC_6MONTH"`
-	Bc1Year         V `json:"BC_1YEAR"`
-	Bc20Year        V `json:"BC_20YEAR"`
-	Bc30Year        V `json:"BC_30YEAR"`
-	Bc1Month        V `json:"BC_1MONTH"`
-	Bc4Year         V // custom
-	Bc6Year         V // custom
-	Bc8Year         V // custom
-}
-type Content struct {
-	Type       string      `json:"-type"`
-	Properties *Properties `json:"properties"`
-}
-type Entry struct {
-	Content *Content `json:"content"`
+// GetRecordForDate returns the data of date, formatted as YYYY-MM-DD.
+func (t *Treasury) GetRecordForDate(date string) (*Record, error) {
+	r := t.records[date]
+	if r == nil {
+		return nil, fmt.Errorf("no record for date %s", date)
+	}
+	return r, nil
 }
-type Feed struct {
-	Updated time.Time `json:"updated"`
-	Entry   []*Entry  `json:"entry"`
+
+// FetchData returns the yield curve of the month of dt, from the cache when it was already fetched.
+func FetchData(ctx context.Context, dt time.Time) (*Treasury, error) {
+	return FetchCurve(ctx, YieldCurve, dt)
 }
 
-func dateString(dt time.Time) string {
-	return fmt.Sprintf("%04d-%02d-%02d", dt.Year(), int(dt.Month()), dt.Day())
+// FetchCurve returns a month of the nominal or real yield curve.
+func FetchCurve(ctx context.Context, ds Dataset, dt time.Time) (*Treasury, error) {
+	if ds != YieldCurve && ds != RealYieldCurve {
+		return nil, fmt.Errorf("%s is not a yield curve", ds)
+	}
+	dt = monthOf(dt)
+	t := newTreasury(ds, dt)
+	data, err := load(ctx, ds, dt, &t.Source)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.setDataFromBytes(data); err != nil {
+		return nil, fmt.Errorf("error reading %s of %s: %w", ds, dateString(dt), err)
+	}
+	return t, nil
 }
@@ -1,77 +1,429 @@
 package treasury
 
 import (
+	"context"
+	"encoding/xml"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/clauderoy790/boc-excel-file-maker/cache"
 	"github.com/stretchr/testify/assert"
 )
 
 func Test_NewTreasury(t *testing.T) {
 	a := assert.New(t)
 	now := time.Now()
-	treas := newTreasury(now)
-	expectedPath := downloadPath + fmt.Sprintf("%04d%02d", now.Year(), int(now.Month()))
-	a.Equal(expectedPath, treas.path)
+	treas := newTreasury(YieldCurve, now)
+	expectedPath := baseURL + "daily_treasury_yield_curve&field_tdr_date_value_month=" + fmt.Sprintf("%04d%02d", now.Year(), int(now.Month()))
+	a.Equal(expectedPath, treas.Source.URL)
+	a.Equal(fmt.Sprintf("daily_treasury_yield_curve %04d-%02d", now.Year(), int(now.Month())), treas.Source.Unit)
 
 }
 
+func readFixture(t *testing.T, name string) []byte {
+	data, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// serveFixture serves a file of testdata in place of the Treasury site.
+func serveFixture(t *testing.T, name string) *httptest.Server {
+	body := readFixture(t, name)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
 func Test_FetchData(t *testing.T) {
 	a := assert.New(t)
-	d := time.Date(2022, 2, 1, 0, 0, 0, 0, time.Local)
-	treas := newTreasury(d)
-	err := treas.fetchData()
+	srv := serveFixture(t, "daily_treasury_yield_curve_202205.xml")
+	treas := newTreasury(YieldCurve, time.Date(2022, 5, 1, 0, 0, 0, 0, time.Local))
+	treas.Source.URL = srv.URL
+	data, err := fetchXML(context.Background(), &treas.Source)
+	a.NoError(err)
+	a.Equal(http.StatusOK, treas.Source.Status)
+	a.NoError(treas.setDataFromBytes(data))
+	a.Len(treas.records, 20)
+
+	r, err := treas.GetRecordForDate("2022-05-02")
+	a.NoError(err)
+	a.Equal(time.Date(2022, 5, 2, 0, 0, 0, 0, time.UTC), r.Date)
+	for tenor, expected := range map[Tenor]float64{
+		Month3: 0.90,
+		Year1:  2.10,
+		Year2:  2.73,
+		Year3:  2.93,
+		Year4:  2.97,
+		Year5:  3.01,
+		Year6:  3.03,
+		Year7:  3.04,
+		Year8:  3.02,
+		Year10: 2.99,
+	} {
+		v, ok := r.Value(tenor)
+		a.True(ok, tenor)
+		a.InDelta(expected, v, 1e-9, tenor)
+	}
+	_, ok := r.Value(Month4)
+	a.False(ok)
+
+	_, err = treas.GetRecordForDate("2022-05-01")
+	a.Error(err)
+}
+
+func Test_loadMidMonth(t *testing.T) {
+	a := assert.New(t)
+	defer func(dir string) { cache.Dir = dir }(cache.Dir)
+	cache.Dir = t.TempDir()
+	srv := serveFixture(t, "daily_treasury_yield_curve_202205.xml")
+	// the first month of the sheets starts mid-month
+	mid := time.Date(2022, 5, 19, 0, 0, 0, 0, time.Local)
+	src := YieldCurve.source(mid)
+	src.URL = srv.URL
+	_, err := load(context.Background(), YieldCurve, mid, &src)
+	a.NoError(err)
+
+	c, err := openCache()
+	a.NoError(err)
+	_, _, ok, err := c.Get(cacheSource, monthKey(YieldCurve, time.Date(2022, 5, 1, 0, 0, 0, 0, time.Local)))
+	a.NoError(err)
+	a.True(ok, "cached under the first of the month")
+	h, err := History(YieldCurve, time.Date(2022, 5, 2, 0, 0, 0, 0, time.Local))
+	a.NoError(err)
+	a.Equal("3.01", h["BC_5YEAR"][0].Value)
+}
+
+func Test_setDataFromBytes(t *testing.T) {
+	a := assert.New(t)
+	data := readFixture(t, "daily_treasury_yield_curve_202302.xml")
+	treas := newTreasury(YieldCurve, time.Date(2023, 2, 1, 0, 0, 0, 0, time.Local))
+	a.NoError(treas.setDataFromBytes(data))
+
+	r, err := treas.GetRecordForDate("2023-02-01")
+	a.NoError(err)
+	v, ok := r.Value(Month4)
+	a.True(ok)
+	a.Equal(4.78, v)
+	_, ok = r.Value(Month1_5)
+	a.False(ok, "null value")
+	v, ok = r.Value(Tenor("BC_50YEAR"))
+	a.True(ok, "tenor added after the parser")
+	a.Equal(3.61, v)
+
+	// a blank 5 years leaves out the tenors derived from it
+	r, err = treas.GetRecordForDate("2023-02-02")
+	a.NoError(err)
+	_, ok = r.Value(Year5)
+	a.False(ok)
+	_, ok = r.Value(Year4)
+	a.False(ok)
+	_, ok = r.Value(Year6)
+	a.False(ok)
+	v, ok = r.Value(Year8)
+	a.True(ok)
+	a.Equal(3.47, v)
+}
+
+func Test_setDataFromBytesInvalid(t *testing.T) {
+	a := assert.New(t)
+	treas := newTreasury(YieldCurve, time.Now())
+	err := treas.setDataFromBytes([]byte(`<feed><entry><content><properties>
+		<NEW_DATE>2022-05-02T00:00:00</NEW_DATE><BC_5YEAR>abc</BC_5YEAR><SOURCE>test</SOURCE>
+	</properties></content></entry></feed>`))
+	var valueErr *InvalidValueError
+	a.True(errors.As(err, &valueErr))
+	a.Equal("BC_5YEAR", valueErr.Field)
+	a.Equal("abc", valueErr.Value)
+
+	treas = newTreasury(YieldCurve, time.Now())
+	a.NoError(treas.setDataFromBytes([]byte(`<feed><entry><content><properties>
+		<NEW_DATE>2022-05-02T00:00:00</NEW_DATE><BC_5YEAR>3.01</BC_5YEAR><SOURCE>test</SOURCE>
+	</properties></content></entry></feed>`)))
+	r, err := treas.GetRecordForDate("2022-05-02")
+	a.NoError(err)
+	a.Equal("test", r.Extra["SOURCE"])
+
+	a.Error(treas.setDataFromBytes([]byte(`<feed><entry><content><properties>
+		<NEW_DATE>May 2</NEW_DATE>
+	</properties></content></entry></feed>`)))
+}
+
+func Test_RealYieldCurve(t *testing.T) {
+	a := assert.New(t)
+	treas := newTreasury(RealYieldCurve, time.Date(2023, 2, 1, 0, 0, 0, 0, time.Local))
+	a.NoError(treas.setDataFromBytes(readFixture(t, "daily_treasury_real_yield_curve_202302.xml")))
+
+	r, err := treas.GetRecordForDate("2023-02-01")
 	a.NoError(err)
-	data := treas.data
+	a.Equal(map[Tenor]float64{Real5Year: 1.34, Real7Year: 1.25, Real10Year: 1.19, Real20Year: 1.26, Real30Year: 1.39}, r.Tenors)
+	r, err = treas.GetRecordForDate("2023-02-02")
+	a.NoError(err)
+	_, ok := r.Value(Real20Year)
+	a.False(ok)
 
-	for _, d := range data.Feed.Entry {
-		props := d.Content.Properties
-		a.False(strings.HasSuffix(props.Date.Content, "T00:00:00"))
+	_, err = FetchCurve(context.Background(), BillRates, time.Now())
+	a.Error(err)
+}
+
+func Test_Bills(t *testing.T) {
+	a := assert.New(t)
+	b := &Bills{records: make(map[string]*BillRecord)}
+	a.NoError(b.setDataFromBytes(readFixture(t, "daily_treasury_bill_rates_202302.xml")))
+
+	r, err := b.GetRecordForDate("2023-02-01")
+	a.NoError(err)
+	a.Len(r.Discount, 6)
+	a.Equal(4.53, r.Discount[Bill4Week])
+	a.Equal(4.61, r.Yield[Bill4Week])
+	a.Equal(4.68, r.Yield[Bill52Week])
+	a.Equal("912796Z77", r.Extra["CUSIP_4WK"])
+
+	r, err = b.GetRecordForDate("2023-02-02")
+	a.NoError(err)
+	_, ok := r.Yield[Bill17Week]
+	a.False(ok)
+	a.Len(r.Yield, 5)
+}
+
+func Test_LongTerm(t *testing.T) {
+	a := assert.New(t)
+	l := &LongTerm{records: make(map[string]*LongTermRecord)}
+	a.NoError(l.setDataFromBytes(readFixture(t, "daily_treasury_long_term_rate_202302.xml")))
+
+	r, err := l.GetRecordForDate("2023-02-01")
+	a.NoError(err)
+	a.Equal(3.68, r.Rates[LongTerm20Year])
+	a.Equal(3.59, r.Rates[LongTermComposite])
+	a.Equal(1.30, r.Rates[RateType("Real_Rate")])
+	if a.NotNil(r.ExtrapolationFactor) {
+		a.Equal(0.123, *r.ExtrapolationFactor)
 	}
-	a.Equal(len(data.Feed.Entry), len(treas.props))
-	p, err := treas.GetPropsForDate("2022-02-01")
-	a.NotNil(p)
-	a.NoError(err)
-	a.Equal("0.19", p.Bc3Month.Content)
-	a.Equal("0.78", p.Bc1Year.Content)
-	a.Equal("1.18", p.Bc2Year.Content)
-	a.Equal("1.39", p.Bc3Year.Content)
-	a.Equal("1.63", p.Bc5Year.Content)
-	a.Equal("1.76", p.Bc7Year.Content)
-	a.Equal("1.81", p.Bc10Year.Content)
-	a.NotEmpty(p.Bc4Year.Content)
-	a.NotEmpty(p.Bc6Year.Content)
-	a.NotEmpty(p.Bc8Year.Content)
-
-
-	p, err = treas.GetPropsForDate("2022-02-16")
-	a.NotNil(p)
-	a.NoError(err)
-	a.Equal("0.38", p.Bc3Month.Content)
-	a.Equal("1.09", p.Bc1Year.Content)
-	a.Equal("1.52", p.Bc2Year.Content)
-	a.Equal("1.75", p.Bc3Year.Content)
-	a.Equal("1.90", p.Bc5Year.Content)
-	a.Equal("2.00", p.Bc7Year.Content)
-	a.Equal("2.03", p.Bc10Year.Content)
-	a.NotEmpty(p.Bc4Year.Content)
-	a.NotEmpty(p.Bc6Year.Content)
-	a.NotEmpty(p.Bc8Year.Content)
-
-	p, err = treas.GetPropsForDate("2022-02-25")
-	a.NotNil(p)
-	a.NoError(err)
-	a.Equal("0.33", p.Bc3Month.Content)
-	a.Equal("1.13", p.Bc1Year.Content)
-	a.Equal("1.55", p.Bc2Year.Content)
-	a.Equal("1.76", p.Bc3Year.Content)
-	a.Equal("1.86", p.Bc5Year.Content)
-	a.Equal("1.96", p.Bc7Year.Content)
-	a.Equal("1.97", p.Bc10Year.Content)
-	a.NotEmpty(p.Bc4Year.Content)
-	a.NotEmpty(p.Bc6Year.Content)
-	a.NotEmpty(p.Bc8Year.Content)
 
+	r, err = l.GetRecordForDate("2023-02-02")
+	a.NoError(err)
+	_, ok := r.Rates[LongTermComposite]
+	a.False(ok)
+	a.Nil(r.ExtrapolationFactor)
+}
+
+func Test_RealLongTerm(t *testing.T) {
+	a := assert.New(t)
+	l := &RealLongTermRates{records: make(map[string]*RealLongTermRecord)}
+	a.NoError(l.setDataFromBytes(readFixture(t, "daily_treasury_real_long_term_202302.xml")))
+
+	r, err := l.GetRecordForDate("2023-02-03")
+	a.NoError(err)
+	a.Equal(1.36, r.Rate)
+	_, err = l.GetRecordForDate("2023-02-02")
+	a.Error(err)
+}
+
+func Test_updateHistory(t *testing.T) {
+	a := assert.New(t)
+	c, err := cache.Open(t.TempDir())
+	a.NoError(err)
+	month := time.Date(2022, 5, 1, 0, 0, 0, 0, time.Local)
+	feed := func(fields string) []byte {
+		return []byte(`<feed><entry><content><properties>
+			<NEW_DATE>2022-05-02T00:00:00</NEW_DATE>` + fields + `
+		</properties></content></entry></feed>`)
+	}
+	first := time.Date(2022, 5, 3, 12, 0, 0, 0, time.UTC)
+	second := first.Add(24 * time.Hour)
+	third := second.Add(24 * time.Hour)
+
+	// the cached month is the first version when there is no history yet
+	revisions, err := updateHistory(c, YieldCurve, month, feed(`<BC_5YEAR>3.01</BC_5YEAR><BC_7YEAR>3.04</BC_7YEAR>`), first,
+		feed(`<BC_5YEAR>3.02</BC_5YEAR><BC_7YEAR>3.04</BC_7YEAR>`), second)
+	a.NoError(err)
+	a.Equal([]Revision{{Dataset: YieldCurve, Date: "2022-05-02", Field: "BC_5YEAR", Old: "3.01", New: "3.02", OldSeenAt: first, SeenAt: second}}, revisions)
+
+	revisions, err = updateHistory(c, YieldCurve, month, nil, time.Time{}, feed(`<BC_5YEAR>3.02</BC_5YEAR>`), third)
+	a.NoError(err)
+	a.Equal([]Revision{{Dataset: YieldCurve, Date: "2022-05-02", Field: "BC_7YEAR", Old: "3.04", New: "", OldSeenAt: first, SeenAt: third}}, revisions)
+
+	revisions, err = updateHistory(c, YieldCurve, month, nil, time.Time{}, feed(`<BC_5YEAR>3.02</BC_5YEAR>`), third.Add(time.Hour))
+	a.NoError(err)
+	a.Empty(revisions)
+
+	logged, err := readRevisions(c, YieldCurve)
+	a.NoError(err)
+	a.Len(logged, 2)
+	a.Equal("3.02", logged[0].New)
+
+	h, err := readHistory(c, historyKey(YieldCurve, month))
+	a.NoError(err)
+	a.Equal([]Version{{Value: "3.01", SeenAt: first}, {Value: "3.02", SeenAt: second}}, h["2022-05-02"]["BC_5YEAR"])
+}
+
+func Test_expired(t *testing.T) {
+	a := assert.New(t)
+	now := time.Date(2023, 6, 15, 12, 0, 0, 0, time.UTC)
+	hourAgo := now.Add(-time.Hour)
+	minuteAgo := now.Add(-time.Minute)
+	tests := []struct {
+		month    time.Time
+		storedAt time.Time
+		maxAge   time.Duration
+		expired  bool
+	}{
+		{time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC), hourAgo, 0, true},
+		{time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC), minuteAgo, 0, false},
+		{time.Date(2023, 4, 1, 0, 0, 0, 0, time.UTC), hourAgo, 0, true},
+		{time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC), hourAgo, 0, false},
+		{time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC), now.AddDate(-1, 0, 0), 0, false},
+		{time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC), now.Add(-48 * time.Hour), 24 * time.Hour, true},
+		{time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC), hourAgo, 24 * time.Hour, false},
+	}
+	defer func(maxAge time.Duration) { MaxAge = maxAge }(MaxAge)
+	for _, tt := range tests {
+		MaxAge = tt.maxAge
+		a.Equal(tt.expired, expired(tt.month, tt.storedAt, now), "%s max age %s", tt.month.Format("2006-01"), tt.maxAge)
+	}
+}
+
+func Test_importLegacy(t *testing.T) {
+	a := assert.New(t)
+	c, err := cache.Open(t.TempDir())
+	a.NoError(err)
+	dir := filepath.Join(t.TempDir(), legacyDir)
+	a.NoError(os.MkdirAll(filepath.Join(dir, string(YieldCurve)), 0755))
+	for name, content := range map[string]string{
+		"2022-05-01.xml":          "may",
+		"2022-06-01.xml":          "june",
+		"2022-05-01.history.json": "{}",
+		revisionsFile:             "",
+		"notes.txt":               "ignored",
+	} {
+		a.NoError(os.WriteFile(filepath.Join(dir, string(YieldCurve), name), []byte(content), 0644))
+	}
+	_, err = c.Put(cacheSource, monthKey(YieldCurve, time.Date(2022, 6, 1, 0, 0, 0, 0, time.Local)), []byte("newer"))
+	a.NoError(err)
+
+	a.NoError(importLegacy(c, dir))
+	entries := c.Entries(cache.Filter{})
+	a.Len(entries, 4)
+	data, _, _, err := c.Get(cacheSource, cacheKey(YieldCurve, "2022-06-01.xml"))
+	a.NoError(err)
+	a.Equal("newer", string(data), "the entries of the cache are left")
+	for _, e := range entries {
+		a.Equal(!strings.HasSuffix(e.Key, ".xml"), e.Kept, e.Key)
+	}
+	_, err = os.Stat(dir)
+	a.True(errors.Is(err, os.ErrNotExist))
+	_, err = os.Stat(dir + importedSuffix)
+	a.NoError(err)
+	a.NoError(importLegacy(c, dir), "nothing left to import")
+}
+
+func Test_importLegacyJSON(t *testing.T) {
+	a := assert.New(t)
+	c, err := cache.Open(t.TempDir())
+	a.NoError(err)
+	dir := filepath.Join(t.TempDir(), legacyDir)
+	a.NoError(os.MkdirAll(dir, 0755))
+	month := func(days ...string) string {
+		var entries []string
+		for _, d := range days {
+			entries = append(entries, `{"content": {"-type": "application/xml", "properties": {`+
+				`"NEW_DATE": {"-type": "Edm.DateTime", "#content": "`+d+`T00:00:00"}, `+
+				`"BC_1MONTH": {"-type": "Edm.Double", "-null": "true"}, `+
+				`"BC_5YEAR": {"-type": "Edm.Double", "#content": "2.92"}}}}`)
+		}
+		return `{"feed": {"entry": [` + strings.Join(entries, ", ") + `]}}`
+	}
+	for name, content := range map[string]string{
+		"2022-05-09.json": month("2022-05-02"),
+		"2022-05-20.json": month("2022-05-02", "2022-05-19"),
+		"2022-06-03.json": `{"feed": {"entry": {"content": {"properties": {"NEW_DATE": {"#content": "2022-06-01T00:00:00"}}}}}}`,
+		"2022-07-01.json": "not json",
+		"notes.txt":       "ignored",
+	} {
+		a.NoError(os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	a.NoError(importLegacy(c, dir))
+	a.Len(c.Entries(cache.Filter{}), 2)
+	data, _, ok, err := c.Get(cacheSource, monthKey(YieldCurve, time.Date(2022, 5, 1, 0, 0, 0, 0, time.Local)))
+	a.NoError(err)
+	a.True(ok)
+	treas := newTreasury(YieldCurve, time.Date(2022, 5, 1, 0, 0, 0, 0, time.Local))
+	a.NoError(treas.setDataFromBytes(data))
+	r, err := treas.GetRecordForDate("2022-05-19")
+	a.NoError(err, "the last day of the month is imported")
+	a.Equal(map[Tenor]float64{Year5: 2.92}, r.Tenors)
+	_, _, ok, err = c.Get(cacheSource, monthKey(YieldCurve, time.Date(2022, 6, 1, 0, 0, 0, 0, time.Local)))
+	a.NoError(err)
+	a.True(ok, "a month of a single entry")
+
+	_, err = os.Stat(filepath.Join(dir+importedSuffix, "2022-07-01.json"))
+	a.NoError(err, "the files not imported are kept")
+}
+
+func Test_observations(t *testing.T) {
+	a := assert.New(t)
+	obs, err := observations(readFixture(t, "daily_treasury_long_term_rate_202302.xml"))
+	a.NoError(err)
+	a.Equal("3.68", obs["2023-02-01"]["BC_20year/RATE"])
+	a.Equal("3.59", obs["2023-02-01"]["Over_10_Years/RATE"])
+
+	obs, err = observations(readFixture(t, "daily_treasury_yield_curve_202302.xml"))
+	a.NoError(err)
+	a.Equal("", obs["2023-02-01"]["BC_1_5MONTH"], "null value")
+	a.Equal("4.78", obs["2023-02-01"]["BC_4MONTH"])
+}
+
+func Test_monthFeed(t *testing.T) {
+	a := assert.New(t)
+	data := readFixture(t, "daily_treasury_yield_curve_202302.xml")
+	all, err := entries(data)
+	a.NoError(err)
+	var feed monthFeed
+	for _, fields := range all {
+		feed.Entries = append(feed.Entries, newMonthEntry(fields))
+	}
+	content, err := xml.Marshal(feed)
+	a.NoError(err)
+
+	original := newTreasury(YieldCurve, time.Date(2023, 2, 1, 0, 0, 0, 0, time.Local))
+	a.NoError(original.setDataFromBytes(data))
+	written := newTreasury(YieldCurve, time.Date(2023, 2, 1, 0, 0, 0, 0, time.Local))
+	a.NoError(written.setDataFromBytes(content))
+	a.Equal(original.records, written.records)
+}
+
+func Test_csvEntries(t *testing.T) {
+	a := assert.New(t)
+	all, err := csvEntries(YieldCurve, []byte("Date,1 Mo,1.5 Month,2 Mo,5 Yr,30 Yr,Notes\n"+
+		"05/03/1990,,,,8.70,8.84,x\n"+
+		"05/02/1990,7.80,,,8.75,8.90,\n"))
+	a.NoError(err)
+	a.Len(all, 2)
+	treas := newTreasury(YieldCurve, time.Date(1990, 5, 1, 0, 0, 0, 0, time.Local))
+	content, err := xml.Marshal(monthFeed{Entries: []monthEntry{newMonthEntry(all[0]), newMonthEntry(all[1])}})
+	a.NoError(err)
+	a.NoError(treas.setDataFromBytes(content))
+	r, err := treas.GetRecordForDate("1990-05-02")
+	a.NoError(err)
+	a.Equal(map[Tenor]float64{Month1: 7.80, Year5: 8.75, Year30: 8.90}, r.Tenors)
+	r, err = treas.GetRecordForDate("1990-05-03")
+	a.NoError(err)
+	_, ok := r.Value(Month1)
+	a.False(ok)
+
+	_, err = csvEntries(BillRates, nil)
+	a.Error(err)
+	_, err = csvEntries(YieldCurve, []byte("Date,1 Mo\n1990-05-02,7.80\n"))
+	a.Error(err)
 }
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/clauderoy790/boc-excel-file-maker/provenance"
+	"github.com/clauderoy790/boc-excel-file-maker/quality"
+	"github.com/clauderoy790/boc-excel-file-maker/rates"
+	"github.com/clauderoy790/boc-excel-file-maker/treasury"
+	"github.com/xuri/excelize/v2"
+)
+
+// treasDataset is the sheet of a Treasury dataset, filled a month at a time.
+type treasDataset struct {
+	sheet   string
+	step    string
+	header  string
+	columns []string
+	// fetch returns the month of dt, where it came from and the row of each of its dates
+	fetch func(ctx context.Context, dt time.Time) (provenance.Record, treasRows, error)
+}
+
+// treasRows returns the row of a date: the date and the value of each column of the dataset.
+type treasRows func(date time.Time) []interface{}
+
+// treasTenors are the tenors of treasColumns, in the same order
+var treasTenors = []rates.Tenor{rates.Year1, rates.Year2, rates.Year3, rates.Year4, rates.Year5, rates.Year6, rates.Year7, rates.Year8, rates.Year10}
+
+var treasColumns = []string{"1 an", "2 ans", "3 ans", "4 ans", "5 ans", "6 ans", "7 ans", "8 ans", "10 ans"}
+
+var realYieldTenors = []treasury.Tenor{treasury.Real5Year, treasury.Real7Year, treasury.Real10Year, treasury.Real20Year, treasury.Real30Year}
+
+var realYieldColumns = []string{"5 ans", "7 ans", "10 ans", "20 ans", "30 ans"}
+
+// billMaturities are the bills of billColumns, in the same order, the sheet has their coupon equivalent
+var billMaturities = []treasury.Bill{treasury.Bill4Week, treasury.Bill6Week, treasury.Bill8Week, treasury.Bill13Week, treasury.Bill17Week, treasury.Bill26Week, treasury.Bill52Week}
+
+var billColumns = []string{"4 sem.", "6 sem.", "8 sem.", "13 sem.", "17 sem.", "26 sem.", "52 sem."}
+
+var longTermTypes = []treasury.RateType{treasury.LongTermComposite, treasury.LongTerm20Year}
+
+var longTermColumns = []string{"Composite > 10 ans", "20 ans"}
+
+var realLongTermColumns = []string{"Moyenne réelle > 10 ans"}
+
+func treasHeaderOf(title string, ds treasury.Dataset) string {
+	return fmt.Sprintf("%s\nSource de chaque mois: feuille Sources\n%s\n", title, ds.TextURL())
+}
+
+// treasDatasets are the sheets of the Treasury datasets, in the workbook's order.
+var treasDatasets = []treasDataset{
+	{
+		sheet:   treasSheet,
+		step:    stepTreasury,
+		header:  treasHeader,
+		columns: treasColumns,
+		// the nominal curve is read like the rate and curve commands read it
+		fetch: func(ctx context.Context, dt time.Time) (provenance.Record, treasRows, error) {
+			src, err := rateClient.Provenance(ctx, rates.Treasury, dt)
+			if err != nil {
+				return provenance.Record{}, nil, err
+			}
+			return src, func(date time.Time) []interface{} { return getTreasRowData(ctx, date) }, nil
+		},
+	},
+	{
+		sheet:   realYieldSheet,
+		step:    stepRealYield,
+		header:  treasHeaderOf("Historique taux réels des obligations", treasury.RealYieldCurve),
+		columns: realYieldColumns,
+		fetch: func(ctx context.Context, dt time.Time) (provenance.Record, treasRows, error) {
+			data, err := treasury.FetchCurve(ctx, treasury.RealYieldCurve, dt)
+			if err != nil {
+				return provenance.Record{}, nil, err
+			}
+			return data.Source, func(date time.Time) []interface{} {
+				record, err := data.GetRecordForDate(dateString(date))
+				return treasRow(date, len(realYieldTenors), func(i int) (float64, bool) {
+					if err != nil {
+						return 0, false
+					}
+					return record.Value(realYieldTenors[i])
+				})
+			}, nil
+		},
+	},
+	{
+		sheet:   billsSheet,
+		step:    stepBills,
+		header:  treasHeaderOf("Historique taux des bons du Trésor (équivalent coupon)", treasury.BillRates),
+		columns: billColumns,
+		fetch: func(ctx context.Context, dt time.Time) (provenance.Record, treasRows, error) {
+			data, err := treasury.FetchBills(ctx, dt)
+			if err != nil {
+				return provenance.Record{}, nil, err
+			}
+			return data.Source, func(date time.Time) []interface{} {
+				record, err := data.GetRecordForDate(dateString(date))
+				return treasRow(date, len(billMaturities), func(i int) (float64, bool) {
+					if err != nil {
+						return 0, false
+					}
+					v, ok := record.Yield[billMaturities[i]]
+					return v, ok
+				})
+			}, nil
+		},
+	},
+	{
+		sheet:   longTermSheet,
+		step:    stepLongTerm,
+		header:  treasHeaderOf("Historique taux à long terme", treasury.LongTermRate),
+		columns: longTermColumns,
+		fetch: func(ctx context.Context, dt time.Time) (provenance.Record, treasRows, error) {
+			data, err := treasury.FetchLongTerm(ctx, dt)
+			if err != nil {
+				return provenance.Record{}, nil, err
+			}
+			return data.Source, func(date time.Time) []interface{} {
+				record, err := data.GetRecordForDate(dateString(date))
+				return treasRow(date, len(longTermTypes), func(i int) (float64, bool) {
+					if err != nil {
+						return 0, false
+					}
+					v, ok := record.Rates[longTermTypes[i]]
+					return v, ok
+				})
+			}, nil
+		},
+	},
+	{
+		sheet:   realLongTermSheet,
+		step:    stepRealLongTerm,
+		header:  treasHeaderOf("Historique taux réels à long terme", treasury.RealLongTerm),
+		columns: realLongTermColumns,
+		fetch: func(ctx context.Context, dt time.Time) (provenance.Record, treasRows, error) {
+			data, err := treasury.FetchRealLongTerm(ctx, dt)
+			if err != nil {
+				return provenance.Record{}, nil, err
+			}
+			return data.Source, func(date time.Time) []interface{} {
+				record, err := data.GetRecordForDate(dateString(date))
+				return treasRow(date, 1, func(int) (float64, bool) {
+					if err != nil {
+						return 0, false
+					}
+					return record.Rate, true
+				})
+			}, nil
+		},
+	},
+}
+
+// writeTreasurySheet writes a row for each day from the start date, fetching the dataset a month at a time.
+func writeTreasurySheet(ctx context.Context, f *excelize.File, opts options, progress progressFunc, ds treasDataset) ([]*quality.Series, error) {
+	sheet := ds.sheet
+	f.NewSheet(sheet)
+	if err := writeHeader(f, sheet, ds.header); err != nil {
+		return nil, err
+	}
+	columns := orderedSelection(ds.columns, opts.columns[sheet])
+	titles := []interface{}{tableTitle}
+	for _, c := range columns {
+		titles = append(titles, c)
+	}
+	if err := setRow(f, sheet, "A5", titles); err != nil {
+		return nil, err
+	}
+
+	currDate := opts.startDate(startDateTreasury)
+	now := opts.endDate()
+	line := 6
+	prevMonth, curMonth := -1, int(currDate.Month())
+	var rows treasRows
+	series := newSheetSeries(sheet, columns, true, true)
+	month, months := 0, monthsBetween(currDate, now)
+	progress(ds.step, month, months)
+	for {
+		if prevMonth != curMonth {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			var src provenance.Record
+			var err error
+			src, rows, err = ds.fetch(ctx, currDate)
+			if err != nil {
+				return nil, &stepError{
+					step:   ds.step,
+					detail: fmt.Sprintf("month %d of %d", month+1, months),
+					err:    fmt.Errorf("error fetching treasury data for date %s: %w", dateString(currDate), err),
+				}
+			}
+			sources.Add(src)
+			slog.Debug("fetched treasury month", "source", treasury.SourceName, "sheet", sheet, "date", dateString(currDate), "cache", string(src.Cache))
+			month++
+			progress(ds.step, month, months)
+		}
+
+		rowData := selectColumns(rows(currDate), ds.columns, columns)
+		if err := setRow(f, sheet, fmt.Sprintf("A%v", line), rowData); err != nil {
+			return nil, err
+		}
+		collectRow(series, currDate, rowData)
+		currDate = currDate.AddDate(0, 0, 1)
+		prevMonth = curMonth
+		curMonth = int(currDate.Month())
+		line++
+		if currDate.After(now) {
+			break
+		}
+	}
+	return series, nil
+}
+
+func getTreasRowData(ctx context.Context, date time.Time) []interface{} {
+	curve, err := rateClient.Curve(ctx, rates.Treasury, date)
+	return treasRow(date, len(treasTenors), func(i int) (float64, bool) {
+		if err != nil {
+			return 0, false
+		}
+		v, ok := curve.Rates[treasTenors[i]]
+		return v, ok
+	})
+}
+
+// treasRow returns the date and n values as fractions, "n/a" for the values missing that day.
+func treasRow(date time.Time, n int, value func(i int) (float64, bool)) []interface{} {
+	row := []interface{}{colDateString(date)}
+	for i := 0; i < n; i++ {
+		v, ok := value(i)
+		if !ok {
+			row = append(row, "n/a")
+			continue
+		}
+		row = append(row, fmt.Sprintf("%.4f", v/100))
+	}
+	return row
+}
@@ -0,0 +1,226 @@
+// Package valet is a client of Valet, the web service of the Bank of Canada,
+// for the observations and metadata of any series or group.
+package valet
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/clauderoy790/boc-excel-file-maker/fetch"
+	"github.com/clauderoy790/boc-excel-file-maker/provenance"
+)
+
+// BaseURL is the address of the Valet API.
+var BaseURL = "https://www.bankofcanada.ca/valet"
+
+// SourceName is the name used for Valet in provenance records.
+const SourceName = "Bank of Canada Valet"
+
+const dateLayout = "2006-01-02"
+
+// Series describes a series of Valet, e.g. BD.CDN.10YR.DQ.YLD.
+type Series struct {
+	Name        string
+	Label       string
+	Description string
+}
+
+// Group describes a group of series, e.g. bond_yields_benchmark.
+type Group struct {
+	Name        string
+	Label       string
+	Description string
+	// Series are the series of the group, sorted by name.
+	Series []Series
+}
+
+// Observations are the values of some series by date.
+type Observations struct {
+	// Series are the series of the observations, in the order they were asked for or by name for a group.
+	Series []Series
+	// Group is set for the observations of a group.
+	Group *Group
+	// Source tells where the observations came from.
+	Source provenance.Record
+	values map[string]map[string]float64
+	dates  []string
+}
+
+// Value returns the value of a series on date and whether it had one that day.
+func (o *Observations) Value(series string, date time.Time) (float64, bool) {
+	v, ok := o.values[date.Format(dateLayout)][series]
+	return v, ok
+}
+
+// Dates returns the dates with observations, in order.
+func (o *Observations) Dates() []time.Time {
+	var dates []time.Time
+	for _, d := range o.dates {
+		if t, err := time.ParseInLocation(dateLayout, d, time.Local); err == nil {
+			dates = append(dates, t)
+		}
+	}
+	return dates
+}
+
+type detail struct {
+	Label       string `json:"label"`
+	Description string `json:"description"`
+}
+
+type response struct {
+	GroupDetail  *detail                      `json:"groupDetail"`
+	SeriesDetail map[string]detail            `json:"seriesDetail"`
+	Observations []map[string]json.RawMessage `json:"observations"`
+}
+
+// value is an observed value, Valet sends them as strings.
+type value struct {
+	V json.RawMessage `json:"v"`
+}
+
+// FetchSeries returns the observations of series between start and end, both included.
+// A zero start or end leaves the range open on that side.
+func FetchSeries(ctx context.Context, series []string, start, end time.Time) (*Observations, error) {
+	if len(series) == 0 {
+		return nil, fmt.Errorf("no series to fetch")
+	}
+	names := strings.Join(series, ",")
+	obs, err := get(ctx, "/observations/"+url.PathEscape(names)+"/json", names, start, end)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range series {
+		if _, ok := obs.detail[name]; !ok {
+			return nil, fmt.Errorf("unknown series %s", name)
+		}
+		obs.Series = append(obs.Series, obs.detail[name])
+	}
+	return &obs.Observations, nil
+}
+
+// FetchGroup returns the observations of the series of a group between start and end, both included.
+func FetchGroup(ctx context.Context, group string, start, end time.Time) (*Observations, error) {
+	obs, err := get(ctx, "/observations/group/"+url.PathEscape(group)+"/json", group, start, end)
+	if err != nil {
+		return nil, err
+	}
+	if obs.groupDetail == nil {
+		return nil, fmt.Errorf("unknown group %s", group)
+	}
+	g := &Group{Name: group, Label: obs.groupDetail.Label, Description: obs.groupDetail.Description}
+	for _, s := range obs.detail {
+		g.Series = append(g.Series, s)
+	}
+	sort.Slice(g.Series, func(i, j int) bool {
+		return g.Series[i].Name < g.Series[j].Name
+	})
+	obs.Series = g.Series
+	obs.Group = g
+	return &obs.Observations, nil
+}
+
+// FetchSeriesInfo returns the metadata of a series.
+func FetchSeriesInfo(ctx context.Context, name string) (Series, error) {
+	resp, err := fetch.Get(ctx, BaseURL+"/series/"+url.PathEscape(name)+"/json")
+	if err != nil {
+		return Series{}, fmt.Errorf("error fetching series %s: %w", name, err)
+	}
+	var info struct {
+		SeriesDetails *struct {
+			Name        string `json:"name"`
+			Label       string `json:"label"`
+			Description string `json:"description"`
+		} `json:"seriesDetails"`
+	}
+	if err := json.Unmarshal(resp.Body, &info); err != nil {
+		return Series{}, fmt.Errorf("error while unmarshalling series %s: %w", name, err)
+	}
+	if info.SeriesDetails == nil {
+		return Series{}, fmt.Errorf("unknown series %s", name)
+	}
+	return Series{Name: name, Label: info.SeriesDetails.Label, Description: info.SeriesDetails.Description}, nil
+}
+
+// fetched are observations with the details of the response, used to build the result.
+type fetched struct {
+	Observations
+	detail      map[string]Series
+	groupDetail *detail
+}
+
+func get(ctx context.Context, path, unit string, start, end time.Time) (*fetched, error) {
+	query := url.Values{}
+	if !start.IsZero() {
+		query.Set("start_date", start.Format(dateLayout))
+	}
+	if !end.IsZero() {
+		query.Set("end_date", end.Format(dateLayout))
+	}
+	u := BaseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	obs := &fetched{detail: make(map[string]Series)}
+	obs.Source = provenance.Record{Source: SourceName, Unit: unit, URL: u}
+	resp, err := fetch.Get(ctx, u)
+	if resp != nil {
+		obs.Source.FetchedAt = resp.FetchedAt
+		obs.Source.Status = resp.Status
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching %s: %w", unit, err)
+	}
+	obs.Source.Hash = provenance.Hash(resp.Body)
+	if err := obs.parse(resp.Body); err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", unit, err)
+	}
+	return obs, nil
+}
+
+func (obs *fetched) parse(body []byte) error {
+	var r response
+	if err := json.Unmarshal(body, &r); err != nil {
+		return fmt.Errorf("error while unmarshalling: %w", err)
+	}
+	obs.groupDetail = r.GroupDetail
+	for name, d := range r.SeriesDetail {
+		obs.detail[name] = Series{Name: name, Label: d.Label, Description: d.Description}
+	}
+	obs.values = make(map[string]map[string]float64)
+	for _, o := range r.Observations {
+		var date string
+		if err := json.Unmarshal(o["d"], &date); err != nil {
+			return fmt.Errorf("invalid observation date %s: %w", o["d"], err)
+		}
+		values := make(map[string]float64)
+		for name, raw := range o {
+			if name == "d" {
+				continue
+			}
+			var v value
+			if err := json.Unmarshal(raw, &v); err != nil {
+				return fmt.Errorf("invalid observation of %s on %s: %w", name, date, err)
+			}
+			s := strings.Trim(string(v.V), `"`)
+			if s == "" || s == "null" {
+				continue
+			}
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return fmt.Errorf("invalid value %q of %s on %s: %w", s, name, date, err)
+			}
+			values[name] = f
+		}
+		obs.values[date] = values
+		obs.dates = append(obs.dates, date)
+	}
+	sort.Strings(obs.dates)
+	return nil
+}
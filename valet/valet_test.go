@@ -0,0 +1,103 @@
+package valet
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// serveValet serves the fixtures of testdata in place of Valet.
+func serveValet(t *testing.T) {
+	files := map[string]string{
+		"/observations/BD.CDN.10YR.DQ.YLD,V39079/json":   "observations_series.json",
+		"/observations/group/bond_yields_benchmark/json": "observations_group.json",
+		"/series/AVG.INTWO/json":                         "series.json",
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, ok := files[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		body, err := os.ReadFile("testdata/" + name)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write(body)
+	}))
+	t.Cleanup(srv.Close)
+	base := BaseURL
+	BaseURL = srv.URL
+	t.Cleanup(func() { BaseURL = base })
+}
+
+func day(d int) time.Time {
+	return time.Date(2022, time.May, d, 0, 0, 0, 0, time.Local)
+}
+
+func Test_FetchSeries(t *testing.T) {
+	a := assert.New(t)
+	serveValet(t)
+	obs, err := FetchSeries(context.Background(), []string{"BD.CDN.10YR.DQ.YLD", "V39079"}, day(2), day(5))
+	a.NoError(err)
+	a.Equal(SourceName, obs.Source.Source)
+	a.Contains(obs.Source.URL, "start_date=2022-05-02")
+	a.Contains(obs.Source.URL, "end_date=2022-05-05")
+	a.NotEmpty(obs.Source.Hash)
+	if a.Len(obs.Series, 2) {
+		a.Equal("10 year", obs.Series[0].Label)
+		a.Equal("Target for the overnight rate", obs.Series[1].Label)
+	}
+	a.Len(obs.Dates(), 4)
+
+	v, ok := obs.Value("BD.CDN.10YR.DQ.YLD", day(3))
+	a.True(ok)
+	a.Equal(2.96, v)
+	_, ok = obs.Value("BD.CDN.10YR.DQ.YLD", day(4))
+	a.False(ok, "blank value")
+	_, ok = obs.Value("BD.CDN.10YR.DQ.YLD", day(5))
+	a.False(ok, "missing value")
+	_, ok = obs.Value("V39079", day(6))
+	a.False(ok, "missing date")
+
+	_, err = FetchSeries(context.Background(), []string{"NOPE"}, day(2), day(5))
+	a.Error(err)
+	_, err = FetchSeries(context.Background(), nil, day(2), day(5))
+	a.Error(err)
+}
+
+func Test_FetchGroup(t *testing.T) {
+	a := assert.New(t)
+	serveValet(t)
+	obs, err := FetchGroup(context.Background(), "bond_yields_benchmark", time.Time{}, time.Time{})
+	a.NoError(err)
+	a.NotContains(obs.Source.URL, "?")
+	if a.NotNil(obs.Group) {
+		a.Equal("Benchmark bond yields", obs.Group.Label)
+	}
+	if a.Len(obs.Series, 2) {
+		a.Equal("BD.CDN.7YR.DQ.YLD", obs.Series[0].Name)
+		a.Equal("BD.CDN.LONG.DQ.YLD", obs.Series[1].Name)
+	}
+	a.Equal([]time.Time{day(2), day(3)}, obs.Dates())
+	v, ok := obs.Value("BD.CDN.LONG.DQ.YLD", day(2))
+	a.True(ok)
+	a.Equal(2.90, v)
+}
+
+func Test_FetchSeriesInfo(t *testing.T) {
+	a := assert.New(t)
+	serveValet(t)
+	s, err := FetchSeriesInfo(context.Background(), "AVG.INTWO")
+	a.NoError(err)
+	a.Equal(Series{Name: "AVG.INTWO", Label: "CORRA", Description: "Canadian Overnight Repo Rate Average (CORRA)"}, s)
+
+	_, err = FetchSeriesInfo(context.Background(), "NOPE")
+	a.Error(err)
+}
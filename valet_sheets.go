@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/clauderoy790/boc-excel-file-maker/quality"
+	"github.com/clauderoy790/boc-excel-file-maker/valet"
+	"github.com/xuri/excelize/v2"
+)
+
+// valetConfig adds Bank of Canada Valet series to the workbook, read from the file given with -valet-config:
+//
+//	{"sheets": [
+//		{"name": "OEC", "columns": [{"series": "BD.CDN.10YR.DQ.YLD", "title": "10 ans"}]},
+//		{"name": "BoC Money Market", "columns": [{"series": "V39079"}, {"series": "AVG.INTWO", "title": "CORRA"}]},
+//		{"name": "BoC Benchmarks", "group": "bond_yields_benchmark"}
+//	]}
+//
+// The OEC sheet gets its series as extra columns, any other name is a new sheet.
+type valetConfig struct {
+	Sheets []valetSheet `json:"sheets"`
+}
+
+type valetSheet struct {
+	Name string `json:"name"`
+	// Group adds a column for each series of a Valet group.
+	Group   string        `json:"group,omitempty"`
+	Columns []valetColumn `json:"columns,omitempty"`
+}
+
+type valetColumn struct {
+	Series string `json:"series"`
+	// Title is the title of the column, the label of the series when empty.
+	Title string `json:"title,omitempty"`
+}
+
+// valetSheets are the sheets of the configuration, none without one.
+var valetSheets []valetSheet
+
+func loadValetConfig(path string) (valetConfig, error) {
+	var cfg valetConfig
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("error reading valet configuration: %w", err)
+	}
+	if err := json.Unmarshal(content, &cfg); err != nil {
+		return cfg, fmt.Errorf("error reading valet configuration %s: %w", path, err)
+	}
+	return cfg, cfg.validate()
+}
+
+func (c valetConfig) validate() error {
+	names := make(map[string]bool)
+	for i, s := range c.Sheets {
+		if s.Name == "" {
+			return fmt.Errorf("valet sheet %d has no name", i+1)
+		}
+		if names[s.Name] {
+			return fmt.Errorf("valet sheet %s is configured twice", s.Name)
+		}
+		names[s.Name] = true
+		if s.Name != oecSheet && contains(allSheets, s.Name) {
+			return fmt.Errorf("valet sheet %s would replace a sheet of the workbook", s.Name)
+		}
+		if s.Group == "" && len(s.Columns) == 0 {
+			return fmt.Errorf("valet sheet %s has no group and no columns", s.Name)
+		}
+		if s.Group != "" && s.Name == oecSheet {
+			return fmt.Errorf("valet groups can only be new sheets")
+		}
+		for _, col := range s.Columns {
+			if col.Series == "" {
+				return fmt.Errorf("valet sheet %s has a column without series", s.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// addValetSheets makes the new sheets of the configuration available, before the prime sheet.
+func addValetSheets(cfg valetConfig) {
+	valetSheets = cfg.Sheets
+	for _, s := range cfg.Sheets {
+		if s.Name == oecSheet {
+			continue
+		}
+		allSheets = insertBefore(allSheets, wsjSheet, s.Name)
+		defaultSheets = insertBefore(defaultSheets, wsjSheet, s.Name)
+	}
+}
+
+func insertBefore(list []string, before, s string) []string {
+	inserted := make([]string, 0, len(list)+1)
+	for _, v := range list {
+		if v == before {
+			inserted = append(inserted, s)
+		}
+		inserted = append(inserted, v)
+	}
+	if len(inserted) == len(list) {
+		inserted = append(inserted, s)
+	}
+	return inserted
+}
+
+// activeValetSheets returns the configured sheets the run writes.
+func activeValetSheets(opts options) []valetSheet {
+	var active []valetSheet
+	for _, s := range valetSheets {
+		if opts.includes(s.Name) {
+			active = append(active, s)
+		}
+	}
+	return active
+}
+
+// valetData is the fetched data of a configured sheet.
+type valetData struct {
+	sheet   valetSheet
+	columns []valetColumn
+	obs     *valet.Observations
+}
+
+func (d *valetData) titles() []string {
+	var titles []string
+	for _, c := range d.columns {
+		titles = append(titles, c.Title)
+	}
+	return titles
+}
+
+// row returns the date and the value of each column, like the other daily sheets.
+func (d *valetData) row(date time.Time) []interface{} {
+	return treasRow(date, len(d.columns), func(i int) (float64, bool) {
+		return d.obs.Value(d.columns[i].Series, date)
+	})
+}
+
+// fetchValet fetches the series of the configured sheets the run writes, by sheet.
+func fetchValet(ctx context.Context, opts options, progress progressFunc) (map[string]*valetData, error) {
+	sheets := activeValetSheets(opts)
+	from := opts.startDate(oecStartDate())
+	to := opts.endDate()
+	data := make(map[string]*valetData)
+	progress(stepValet, 0, len(sheets))
+	for i, s := range sheets {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		d := &valetData{sheet: s}
+		var err error
+		if s.Group != "" {
+			d.obs, err = valet.FetchGroup(ctx, s.Group, from, to)
+		} else {
+			var ids []string
+			for _, c := range s.Columns {
+				ids = append(ids, c.Series)
+			}
+			d.obs, err = valet.FetchSeries(ctx, ids, from, to)
+		}
+		if err != nil {
+			return nil, &stepError{step: stepValet, detail: s.Name, err: err}
+		}
+		sources.Add(d.obs.Source)
+		d.columns = valetColumns(s, d.obs)
+		slog.Info("fetched valet series", "source", valet.SourceName, "step", stepValet, "sheet", s.Name, "series", len(d.columns))
+		data[s.Name] = d
+		progress(stepValet, i+1, len(sheets))
+	}
+	return data, nil
+}
+
+// valetColumns returns the columns of a sheet, titled with the labels of their series when the configuration has no title.
+func valetColumns(s valetSheet, obs *valet.Observations) []valetColumn {
+	if s.Group != "" {
+		var columns []valetColumn
+		for _, series := range obs.Series {
+			columns = append(columns, valetColumn{Series: series.Name, Title: series.Label})
+		}
+		return columns
+	}
+	labels := make(map[string]string)
+	for _, series := range obs.Series {
+		labels[series.Name] = series.Label
+	}
+	columns := make([]valetColumn, len(s.Columns))
+	for i, c := range s.Columns {
+		columns[i] = c
+		if c.Title == "" {
+			columns[i].Title = labels[c.Series]
+		}
+	}
+	return columns
+}
+
+// writeValetSheet writes a new sheet of Valet series, a row for each day from the start date.
+func writeValetSheet(f *excelize.File, opts options, d *valetData) ([]*quality.Series, error) {
+	sheet := d.sheet.Name
+	f.NewSheet(sheet)
+	title := sheet
+	if d.obs.Group != nil {
+		title = d.obs.Group.Label
+	}
+	if err := writeHeader(f, sheet, fmt.Sprintf("%s\nSource: feuille Sources\n%s\n", title, valet.BaseURL)); err != nil {
+		return nil, err
+	}
+	titles := []interface{}{tableTitle}
+	for _, t := range d.titles() {
+		titles = append(titles, t)
+	}
+	if err := setRow(f, sheet, "A5", titles); err != nil {
+		return nil, err
+	}
+	currDate := opts.startDate(oecStartDate())
+	now := opts.endDate()
+	series := newSheetSeries(sheet, d.titles(), true, true)
+	for line := 6; !currDate.After(now); line++ {
+		row := d.row(currDate)
+		if err := setRow(f, sheet, fmt.Sprintf("A%v", line), row); err != nil {
+			return nil, err
+		}
+		collectRow(series, currDate, row)
+		currDate = currDate.AddDate(0, 0, 1)
+	}
+	return series, nil
+}
@@ -0,0 +1,355 @@
+// Package wbdiff compares two workbooks made by the program: the days and series added and
+// removed in the daily sheets, the values that changed, and the cells that changed in the others.
+package wbdiff
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Change is a value that changed. Date and Series locate it in a daily sheet, Cell in another.
+type Change struct {
+	Date   string `json:"date,omitempty"`
+	Series string `json:"series,omitempty"`
+	Cell   string `json:"cell,omitempty"`
+	Old    string `json:"old"`
+	New    string `json:"new"`
+}
+
+// Sheet is what changed in a sheet.
+type Sheet struct {
+	Name string `json:"name"`
+	// Added and Removed tell that the whole sheet is only in the new or the old workbook.
+	Added   bool `json:"added,omitempty"`
+	Removed bool `json:"removed,omitempty"`
+	// AddedDates and RemovedDates are the days only in the new or the old daily sheet.
+	AddedDates   []string `json:"addedDates,omitempty"`
+	RemovedDates []string `json:"removedDates,omitempty"`
+	// AddedSeries and RemovedSeries are the column titles only in the new or the old daily sheet.
+	AddedSeries   []string `json:"addedSeries,omitempty"`
+	RemovedSeries []string `json:"removedSeries,omitempty"`
+	Changes       []Change `json:"changes,omitempty"`
+}
+
+func (s *Sheet) empty() bool {
+	return !s.Added && !s.Removed && len(s.AddedDates) == 0 && len(s.RemovedDates) == 0 &&
+		len(s.AddedSeries) == 0 && len(s.RemovedSeries) == 0 && len(s.Changes) == 0
+}
+
+// Report is what changed between two workbooks, by sheet, only the sheets that changed being there.
+type Report struct {
+	Old    string  `json:"old"`
+	New    string  `json:"new"`
+	Sheets []Sheet `json:"sheets"`
+}
+
+// Options tell how to read the workbooks.
+type Options struct {
+	// TableTitle is the first cell of the title row of the daily sheets, whose first column is the date.
+	TableTitle string
+	// Ignore are the sheets to leave out, like those that change on every run.
+	Ignore []string
+}
+
+// Compare returns what changed from the workbook at oldPath to the one at newPath.
+func Compare(oldPath, newPath string, opts Options) (*Report, error) {
+	oldFile, err := excelize.OpenFile(oldPath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening %s: %w", oldPath, err)
+	}
+	defer oldFile.Close()
+	newFile, err := excelize.OpenFile(newPath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening %s: %w", newPath, err)
+	}
+	defer newFile.Close()
+	report, err := CompareFiles(oldFile, newFile, opts)
+	if err != nil {
+		return nil, err
+	}
+	report.Old, report.New = oldPath, newPath
+	return report, nil
+}
+
+// CompareFiles returns what changed from old to new.
+func CompareFiles(old, new *excelize.File, opts Options) (*Report, error) {
+	report := &Report{Sheets: []Sheet{}}
+	ignored := make(map[string]bool)
+	for _, s := range opts.Ignore {
+		ignored[s] = true
+	}
+	oldSheets := make(map[string]bool)
+	for _, s := range old.GetSheetList() {
+		oldSheets[s] = true
+	}
+	for _, name := range new.GetSheetList() {
+		if ignored[name] {
+			continue
+		}
+		newRows, err := new.GetRows(name)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", name, err)
+		}
+		if !oldSheets[name] {
+			report.Sheets = append(report.Sheets, Sheet{Name: name, Added: true})
+			continue
+		}
+		oldRows, err := old.GetRows(name)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", name, err)
+		}
+		s := compareSheet(name, oldRows, newRows, opts.TableTitle)
+		if !s.empty() {
+			report.Sheets = append(report.Sheets, s)
+		}
+	}
+	newSheets := make(map[string]bool)
+	for _, s := range new.GetSheetList() {
+		newSheets[s] = true
+	}
+	for _, name := range old.GetSheetList() {
+		if !ignored[name] && !newSheets[name] {
+			report.Sheets = append(report.Sheets, Sheet{Name: name, Removed: true})
+		}
+	}
+	return report, nil
+}
+
+// titleRow returns the index of the title row of a daily sheet.
+func titleRow(rows [][]string, title string) (int, bool) {
+	if title == "" {
+		return 0, false
+	}
+	for i, row := range rows {
+		if len(row) > 0 && row[0] == title {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func compareSheet(name string, oldRows, newRows [][]string, title string) Sheet {
+	s := Sheet{Name: name}
+	oldTitle, oldTable := titleRow(oldRows, title)
+	newTitle, newTable := titleRow(newRows, title)
+	if !oldTable || !newTable {
+		s.Changes = compareCells(oldRows, newRows)
+		return s
+	}
+	// the headers above the titles are compared as cells
+	s.Changes = compareCells(oldRows[:oldTitle], newRows[:newTitle])
+
+	oldByDate := rowsByDate(oldRows[oldTitle+1:])
+	newByDate := rowsByDate(newRows[newTitle+1:])
+	newTitles, oldTitles := newRows[newTitle], oldRows[oldTitle]
+	// a series only in one of the sheets has no values to compare
+	added := make(map[string]bool)
+	for _, series := range newTitles[1:] {
+		if series != "" && indexOf(oldTitles, series) < 0 {
+			s.AddedSeries = append(s.AddedSeries, series)
+			added[series] = true
+		}
+	}
+	for _, series := range oldTitles[1:] {
+		if series != "" && indexOf(newTitles, series) < 0 {
+			s.RemovedSeries = append(s.RemovedSeries, series)
+		}
+	}
+	var dates []string
+	for date := range newByDate {
+		dates = append(dates, date)
+	}
+	sort.Slice(dates, func(i, j int) bool { return newByDate[dates[i]].index < newByDate[dates[j]].index })
+	for _, date := range dates {
+		newRow := newByDate[date]
+		oldRow, ok := oldByDate[date]
+		if !ok {
+			s.AddedDates = append(s.AddedDates, date)
+			continue
+		}
+		for c := 1; c < len(newTitles) || c < len(newRow.cells); c++ {
+			series := cell(newTitles, c)
+			if added[series] {
+				continue
+			}
+			if series == "" {
+				series, _ = excelize.ColumnNumberToName(c + 1)
+			}
+			// the columns are matched by title, a column can move between runs
+			oc := indexOf(oldTitles, series)
+			if oc < 0 {
+				oc = c
+			}
+			if o, n := cell(oldRow.cells, oc), cell(newRow.cells, c); !same(o, n) {
+				s.Changes = append(s.Changes, Change{Date: date, Series: series, Old: o, New: n})
+			}
+		}
+	}
+	var removed []string
+	for date := range oldByDate {
+		if _, ok := newByDate[date]; !ok {
+			removed = append(removed, date)
+		}
+	}
+	sort.Slice(removed, func(i, j int) bool { return oldByDate[removed[i]].index < oldByDate[removed[j]].index })
+	s.RemovedDates = removed
+	return s
+}
+
+type dateRow struct {
+	index int
+	cells []string
+}
+
+func rowsByDate(rows [][]string) map[string]dateRow {
+	byDate := make(map[string]dateRow)
+	for i, row := range rows {
+		if len(row) == 0 || row[0] == "" {
+			continue
+		}
+		byDate[row[0]] = dateRow{index: i, cells: row}
+	}
+	return byDate
+}
+
+func compareCells(oldRows, newRows [][]string) []Change {
+	var changes []Change
+	for r := 0; r < len(oldRows) || r < len(newRows); r++ {
+		var oldRow, newRow []string
+		if r < len(oldRows) {
+			oldRow = oldRows[r]
+		}
+		if r < len(newRows) {
+			newRow = newRows[r]
+		}
+		for c := 0; c < len(oldRow) || c < len(newRow); c++ {
+			if o, n := cell(oldRow, c), cell(newRow, c); !same(o, n) {
+				name, _ := excelize.CoordinatesToCellName(c+1, r+1)
+				changes = append(changes, Change{Cell: name, Old: o, New: n})
+			}
+		}
+	}
+	return changes
+}
+
+// same tells whether two cells have the same value, numbers being compared as numbers
+// since a value written as text, like 0.0340, reads back as 0.034 once written as a number.
+func same(o, n string) bool {
+	if o == n {
+		return true
+	}
+	a, errA := strconv.ParseFloat(o, 64)
+	b, errB := strconv.ParseFloat(n, 64)
+	return errA == nil && errB == nil && a == b
+}
+
+func cell(row []string, c int) string {
+	if c < len(row) {
+		return row[c]
+	}
+	return ""
+}
+
+func indexOf(row []string, v string) int {
+	for i, s := range row {
+		if s == v {
+			return i
+		}
+	}
+	return -1
+}
+
+// WriteText writes the report for a person to read.
+func (r *Report) WriteText(w io.Writer) error {
+	if len(r.Sheets) == 0 {
+		_, err := fmt.Fprintf(w, "No changes between %s and %s\n", r.Old, r.New)
+		return err
+	}
+	for _, s := range r.Sheets {
+		switch {
+		case s.Added:
+			fmt.Fprintf(w, "%s: new sheet\n", s.Name)
+			continue
+		case s.Removed:
+			fmt.Fprintf(w, "%s: removed sheet\n", s.Name)
+			continue
+		}
+		fmt.Fprintf(w, "%s:\n", s.Name)
+		if len(s.AddedDates) > 0 {
+			fmt.Fprintf(w, "  %d added dates: %s to %s\n", len(s.AddedDates), s.AddedDates[0], s.AddedDates[len(s.AddedDates)-1])
+		}
+		for _, d := range s.RemovedDates {
+			fmt.Fprintf(w, "  removed %s\n", d)
+		}
+		for _, series := range s.AddedSeries {
+			fmt.Fprintf(w, "  new series %s\n", series)
+		}
+		for _, series := range s.RemovedSeries {
+			fmt.Fprintf(w, "  removed series %s\n", series)
+		}
+		for _, c := range s.Changes {
+			where := c.Cell
+			if c.Date != "" {
+				where = c.Date + " " + c.Series
+			}
+			fmt.Fprintf(w, "  %s: %q -> %q\n", where, c.Old, c.New)
+		}
+	}
+	return nil
+}
+
+// WriteJSON writes the report as JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteSheet writes the report in a sheet of f, one line per change, replacing the sheet if it exists.
+func (r *Report) WriteSheet(f *excelize.File, sheet string) error {
+	if f.GetSheetIndex(sheet) != -1 {
+		f.DeleteSheet(sheet)
+	}
+	f.NewSheet(sheet)
+	rows := [][]interface{}{
+		{"Ancien fichier", r.Old},
+		{"Nouveau fichier", r.New},
+		{},
+		{"Feuille", "Changement", "Date", "Série", "Cellule", "Ancienne valeur", "Nouvelle valeur"},
+	}
+	for _, s := range r.Sheets {
+		switch {
+		case s.Added:
+			rows = append(rows, []interface{}{s.Name, "Feuille ajoutée"})
+		case s.Removed:
+			rows = append(rows, []interface{}{s.Name, "Feuille retirée"})
+		}
+		for _, d := range s.AddedDates {
+			rows = append(rows, []interface{}{s.Name, "Date ajoutée", d})
+		}
+		for _, d := range s.RemovedDates {
+			rows = append(rows, []interface{}{s.Name, "Date retirée", d})
+		}
+		for _, series := range s.AddedSeries {
+			rows = append(rows, []interface{}{s.Name, "Série ajoutée", "", series})
+		}
+		for _, series := range s.RemovedSeries {
+			rows = append(rows, []interface{}{s.Name, "Série retirée", "", series})
+		}
+		for _, c := range s.Changes {
+			rows = append(rows, []interface{}{s.Name, "Valeur modifiée", c.Date, c.Series, c.Cell, c.Old, c.New})
+		}
+	}
+	for i, row := range rows {
+		cell, _ := excelize.CoordinatesToCellName(1, i+1)
+		if err := f.SetSheetRow(sheet, cell, &row); err != nil {
+			return fmt.Errorf("error writing %s: %w", sheet, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package wbdiff
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xuri/excelize/v2"
+)
+
+const title = "Taux en date du:"
+
+func workbook(t *testing.T, sheets map[string][][]interface{}) *excelize.File {
+	f := excelize.NewFile()
+	for name, rows := range sheets {
+		f.NewSheet(name)
+		for i, row := range rows {
+			row := row
+			cell, _ := excelize.CoordinatesToCellName(1, i+1)
+			assert.NoError(t, f.SetSheetRow(name, cell, &row))
+		}
+	}
+	f.DeleteSheet("Sheet1")
+	return f
+}
+
+func TestCompareFiles(t *testing.T) {
+	old := workbook(t, map[string][][]interface{}{
+		"OEC": {
+			{"Obligations"},
+			{},
+			{title, "2 ans", "5 ans", "10 ans"},
+			{"1/2/2024", "0.0400", "0.0350", "0.0330"},
+			{"1/3/2024", "0.0410", "0.0360", "0.0340"},
+		},
+		"Wall St Prime": {{"Prime", "8.50", "0.0340"}},
+		"Sources":       {{"fetched", "yesterday"}},
+		"Gone":          {{"x"}},
+	})
+	new := workbook(t, map[string][][]interface{}{
+		"OEC": {
+			{"Obligations"},
+			{},
+			{title, "5 ans", "2 ans", "3 ans"},
+			{"1/3/2024", "0.0360", "0.0415", "0.0380"},
+			{"1/4/2024", "0.0370", "0.0420", "0.0390"},
+		},
+		"Wall St Prime": {{"Prime", "8.25", 0.034}},
+		"Sources":       {{"fetched", "today"}},
+		"FX":            {{"x"}},
+	})
+
+	report, err := CompareFiles(old, new, Options{TableTitle: title, Ignore: []string{"Sources"}})
+	assert.NoError(t, err)
+	byName := make(map[string]Sheet)
+	for _, s := range report.Sheets {
+		byName[s.Name] = s
+	}
+	assert.Len(t, byName, 4)
+	assert.Equal(t, Sheet{
+		Name:          "OEC",
+		AddedDates:    []string{"1/4/2024"},
+		RemovedDates:  []string{"1/2/2024"},
+		AddedSeries:   []string{"3 ans"},
+		RemovedSeries: []string{"10 ans"},
+		Changes:       []Change{{Date: "1/3/2024", Series: "2 ans", Old: "0.0410", New: "0.0415"}},
+	}, byName["OEC"])
+	assert.Equal(t, []Change{{Cell: "B1", Old: "8.50", New: "8.25"}}, byName["Wall St Prime"].Changes)
+	assert.True(t, byName["FX"].Added)
+	assert.True(t, byName["Gone"].Removed)
+
+	var text bytes.Buffer
+	assert.NoError(t, report.WriteText(&text))
+	assert.Contains(t, text.String(), `1/3/2024 2 ans: "0.0410" -> "0.0415"`)
+	assert.Contains(t, text.String(), "removed 1/2/2024")
+	assert.Contains(t, text.String(), "new series 3 ans")
+	assert.Contains(t, text.String(), "removed series 10 ans")
+
+	var out bytes.Buffer
+	assert.NoError(t, report.WriteJSON(&out))
+	var decoded Report
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &decoded))
+	assert.Equal(t, *report, decoded)
+
+	assert.NoError(t, report.WriteSheet(new, "Changes"))
+	rows, err := new.GetRows("Changes")
+	assert.NoError(t, err)
+	assert.Contains(t, rows, []string{"OEC", "Valeur modifiée", "1/3/2024", "2 ans", "", "0.0410", "0.0415"})
+	assert.Contains(t, rows, []string{"OEC", "Série retirée", "", "10 ans"})
+}
+
+func TestCompareFilesNoChanges(t *testing.T) {
+	sheets := map[string][][]interface{}{"OEC": {{title, "2 ans"}, {"1/2/2024", "0.0400"}}}
+	report, err := CompareFiles(workbook(t, sheets), workbook(t, sheets), Options{TableTitle: title})
+	assert.NoError(t, err)
+	assert.Empty(t, report.Sheets)
+	var text bytes.Buffer
+	assert.NoError(t, report.WriteText(&text))
+	assert.Contains(t, text.String(), "No changes")
+}
@@ -0,0 +1,337 @@
+// Package xltemplate fills a workbook made by the user with series, leaving the rest of it,
+// formulas and formatting included, as it is.
+//
+// A template has three kinds of targets:
+//   - placeholders in cells like {{oec.5y.latest}}, {{oec.5y.date}} or {{oec.5y.2023-06-08}},
+//   - defined names of single cells like OEC_5Y_LATEST or OEC_5Y_DATE,
+//   - defined names like OEC_DAILY whose first row is a header naming the columns, by key or
+//     by title, and "date"; the rows of the source are written below it and the name is
+//     extended to cover them.
+package xltemplate
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Observation is the value of a series at a date.
+type Observation struct {
+	Date  time.Time
+	Value float64
+}
+
+// Series is a column of a source, in ascending dates.
+type Series struct {
+	// Key names the series in the source, like 5y.
+	Key string
+	// Title is the title of the column in the generated workbook, also accepted in headers.
+	Title        string
+	Observations []Observation
+}
+
+// Source is a sheet of the generated workbook.
+type Source struct {
+	// Key names the source, like oec.
+	Key    string
+	Series []Series
+}
+
+// Result counts the targets filled.
+type Result struct {
+	Placeholders int
+	Cells        int
+	Tables       int
+	Rows         int
+}
+
+// Fields of placeholders and defined names.
+const (
+	FieldLatest = "latest"
+	FieldDate   = "date"
+	// Daily is the suffix of the defined names of daily tables.
+	Daily = "DAILY"
+)
+
+var placeholderRegexp = regexp.MustCompile(`\{\{\s*([a-z0-9_]+)\.([a-z0-9_]+)\.([a-z0-9_-]+)\s*\}\}`)
+
+// Fill writes the sources in the targets of f. A placeholder or a daily table header naming a
+// series the sources don't have is an error, so that a typo doesn't leave a stale value.
+func Fill(f *excelize.File, sources []Source) (*Result, error) {
+	res := &Result{}
+	for _, sheet := range f.GetSheetList() {
+		if err := fillPlaceholders(f, sheet, sources, res); err != nil {
+			return nil, err
+		}
+	}
+	for _, dn := range f.GetDefinedName() {
+		if err := fillName(f, dn, sources, res); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func findSeries(sources []Source, key, series string) (*Series, bool) {
+	for i := range sources {
+		if !strings.EqualFold(sources[i].Key, key) {
+			continue
+		}
+		for j := range sources[i].Series {
+			if strings.EqualFold(sources[i].Series[j].Key, series) {
+				return &sources[i].Series[j], true
+			}
+		}
+	}
+	return nil, false
+}
+
+// value returns the value of a field of s: the latest value, its date or the value at a YYYY-MM-DD date.
+func (s *Series) value(field string) (interface{}, error) {
+	if len(s.Observations) == 0 {
+		return "", nil
+	}
+	last := s.Observations[len(s.Observations)-1]
+	switch field {
+	case FieldLatest:
+		return last.Value, nil
+	case FieldDate:
+		return last.Date, nil
+	}
+	date, err := time.ParseInLocation("2006-01-02", field, time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("invalid field %q, want latest, date or a YYYY-MM-DD date", field)
+	}
+	if o, ok := observation(s, date); ok {
+		return o.Value, nil
+	}
+	// no observation that day, the cell is left empty rather than given another day's value
+	return "", nil
+}
+
+func fillPlaceholders(f *excelize.File, sheet string, sources []Source, res *Result) error {
+	rows, err := f.GetRows(sheet, excelize.Options{RawCellValue: true})
+	if err != nil {
+		return fmt.Errorf("error reading sheet %s: %w", sheet, err)
+	}
+	for r, row := range rows {
+		for c, text := range row {
+			matches := placeholderRegexp.FindAllStringSubmatchIndex(text, -1)
+			if len(matches) == 0 {
+				continue
+			}
+			cell, _ := excelize.CoordinatesToCellName(c+1, r+1)
+			values := make([]interface{}, len(matches))
+			for i, m := range matches {
+				key, name, field := text[m[2]:m[3]], text[m[4]:m[5]], text[m[6]:m[7]]
+				s, ok := findSeries(sources, key, name)
+				if !ok {
+					return fmt.Errorf("%s!%s: unknown series %s.%s", sheet, cell, key, name)
+				}
+				if values[i], err = s.value(field); err != nil {
+					return fmt.Errorf("%s!%s: %w", sheet, cell, err)
+				}
+			}
+			// a cell that is only a placeholder gets the typed value so formulas can use it
+			if len(matches) == 1 && strings.TrimSpace(text) == text[matches[0][0]:matches[0][1]] {
+				err = f.SetCellValue(sheet, cell, values[0])
+			} else {
+				i := 0
+				err = f.SetCellValue(sheet, cell, placeholderRegexp.ReplaceAllStringFunc(text, func(string) string {
+					v := values[i]
+					i++
+					return format(v)
+				}))
+			}
+			if err != nil {
+				return fmt.Errorf("error filling %s!%s: %w", sheet, cell, err)
+			}
+			res.Placeholders += len(matches)
+		}
+	}
+	return nil
+}
+
+func format(v interface{}) string {
+	switch v := v.(type) {
+	case time.Time:
+		return v.Format("2006-01-02")
+	case float64:
+		return fmt.Sprintf("%.4f", v)
+	}
+	return fmt.Sprint(v)
+}
+
+var refRegexp = regexp.MustCompile(`^=?(?:'((?:[^']|'')+)'|([^!]+))!\$?([A-Z]+)\$?(\d+)(?::\$?([A-Z]+)\$?(\d+))?$`)
+
+// parseRef returns the sheet, the top left cell and the last column of a reference like
+// 'My Sheet'!$A$1:$C$4, the last column being 0 for a single cell.
+func parseRef(ref string) (sheet, cell string, lastCol int, ok bool) {
+	m := refRegexp.FindStringSubmatch(strings.TrimSpace(ref))
+	if m == nil {
+		return "", "", 0, false
+	}
+	sheet = m[2]
+	if m[1] != "" {
+		sheet = strings.ReplaceAll(m[1], "''", "'")
+	}
+	if m[5] != "" {
+		lastCol, _ = excelize.ColumnNameToNumber(m[5])
+	}
+	return sheet, m[3] + m[4], lastCol, true
+}
+
+func fillName(f *excelize.File, dn excelize.DefinedName, sources []Source, res *Result) error {
+	sheet, cell, lastCol, ok := parseRef(dn.RefersTo)
+	if !ok {
+		return nil
+	}
+	name := strings.ToUpper(dn.Name)
+	for _, src := range sources {
+		prefix := strings.ToUpper(src.Key) + "_"
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if name == prefix+Daily {
+			return fillTable(f, dn, sheet, cell, lastCol, src, res)
+		}
+		for i := range src.Series {
+			s := &src.Series[i]
+			for _, field := range []string{FieldLatest, FieldDate} {
+				if name != prefix+strings.ToUpper(s.Key+"_"+field) {
+					continue
+				}
+				v, _ := s.value(field)
+				if err := f.SetCellValue(sheet, cell, v); err != nil {
+					return fmt.Errorf("error filling %s: %w", dn.Name, err)
+				}
+				res.Cells++
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// fillTable writes the rows of a source below the header at cell, up to lastCol or the first empty
+// header, giving each column the style of its first row, and extends the defined name to the rows written.
+func fillTable(f *excelize.File, dn excelize.DefinedName, sheet, cell string, lastCol int, src Source, res *Result) error {
+	col, row, err := excelize.CellNameToCoordinates(cell)
+	if err != nil {
+		return err
+	}
+	rows, err := f.GetRows(sheet)
+	if err != nil {
+		return fmt.Errorf("error reading sheet %s: %w", sheet, err)
+	}
+	if row > len(rows) {
+		return fmt.Errorf("%s: no header at %s!%s", dn.Name, sheet, cell)
+	}
+	header := rows[row-1]
+	type column struct {
+		col    int
+		series *Series
+		date   bool
+		style  int
+	}
+	var columns []column
+	for c := col - 1; c < len(header) && (lastCol == 0 || c < lastCol); c++ {
+		title := strings.TrimSpace(header[c])
+		if title == "" {
+			break
+		}
+		first, _ := excelize.CoordinatesToCellName(c+1, row+1)
+		style, _ := f.GetCellStyle(sheet, first)
+		if strings.EqualFold(title, FieldDate) {
+			columns = append(columns, column{col: c + 1, date: true, style: style})
+			continue
+		}
+		s := tableSeries(src, title)
+		if s == nil {
+			return fmt.Errorf("%s: unknown column %q of %s", dn.Name, title, src.Key)
+		}
+		columns = append(columns, column{col: c + 1, series: s, style: style})
+	}
+
+	dates := sourceDates(src)
+	for i, date := range dates {
+		for _, c := range columns {
+			var v interface{} = date
+			if !c.date {
+				v = ""
+				if o, ok := observation(c.series, date); ok {
+					v = o.Value
+				}
+			}
+			target, _ := excelize.CoordinatesToCellName(c.col, row+1+i)
+			if err := f.SetCellValue(sheet, target, v); err != nil {
+				return fmt.Errorf("error filling %s: %w", dn.Name, err)
+			}
+			if c.style != 0 && i > 0 {
+				if err := f.SetCellStyle(sheet, target, target, c.style); err != nil {
+					return fmt.Errorf("error filling %s: %w", dn.Name, err)
+				}
+			}
+		}
+	}
+	if len(columns) > 0 {
+		from, _ := excelize.CoordinatesToCellName(col, row, true)
+		to, _ := excelize.CoordinatesToCellName(columns[len(columns)-1].col, row+len(dates), true)
+		if err := f.DeleteDefinedName(&dn); err != nil {
+			return fmt.Errorf("error updating %s: %w", dn.Name, err)
+		}
+		scope := dn.Scope
+		if scope == "Workbook" {
+			scope = ""
+		}
+		if err := f.SetDefinedName(&excelize.DefinedName{Name: dn.Name, Comment: dn.Comment, RefersTo: quoteSheet(sheet) + "!" + from + ":" + to, Scope: scope}); err != nil {
+			return fmt.Errorf("error updating %s: %w", dn.Name, err)
+		}
+	}
+	res.Tables++
+	res.Rows += len(dates)
+	return nil
+}
+
+func tableSeries(src Source, title string) *Series {
+	for i := range src.Series {
+		s := &src.Series[i]
+		if strings.EqualFold(s.Key, title) || strings.EqualFold(s.Title, title) {
+			return s
+		}
+	}
+	return nil
+}
+
+// sourceDates returns the dates with an observation in any series of the source, in order.
+func sourceDates(src Source) []time.Time {
+	seen := make(map[string]time.Time)
+	for _, s := range src.Series {
+		for _, o := range s.Observations {
+			seen[o.Date.Format("2006-01-02")] = o.Date
+		}
+	}
+	dates := make([]time.Time, 0, len(seen))
+	for _, d := range seen {
+		dates = append(dates, d)
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+	return dates
+}
+
+func observation(s *Series, date time.Time) (Observation, bool) {
+	day := date.Format("2006-01-02")
+	i := sort.Search(len(s.Observations), func(i int) bool { return s.Observations[i].Date.Format("2006-01-02") >= day })
+	if i < len(s.Observations) && s.Observations[i].Date.Format("2006-01-02") == day {
+		return s.Observations[i], true
+	}
+	return Observation{}, false
+}
+
+func quoteSheet(sheet string) string {
+	return "'" + strings.ReplaceAll(sheet, "'", "''") + "'"
+}
@@ -0,0 +1,109 @@
+package xltemplate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xuri/excelize/v2"
+)
+
+func day(d int) time.Time {
+	return time.Date(2023, time.June, d, 0, 0, 0, 0, time.Local)
+}
+
+var sources = []Source{{
+	Key: "oec",
+	Series: []Series{
+		{Key: "1y", Title: "1 an", Observations: []Observation{{day(5), 0.045}, {day(6), 0.046}, {day(8), 0.047}}},
+		{Key: "5y", Title: "5 ans", Observations: []Observation{{day(5), 0.034}, {day(8), 0.035}}},
+	},
+}}
+
+func Test_Fill(t *testing.T) {
+	a := assert.New(t)
+	f := excelize.NewFile()
+	a.NoError(f.SetCellValue("Sheet1", "A1", "{{oec.5y.latest}}"))
+	a.NoError(f.SetCellValue("Sheet1", "A2", "5 ans au {{oec.5y.date}}: {{oec.5y.latest}}"))
+	a.NoError(f.SetCellValue("Sheet1", "A3", "{{oec.1y.2023-06-06}}"))
+	a.NoError(f.SetCellValue("Sheet1", "A4", "{{oec.5y.2023-06-06}}"))
+	a.NoError(f.SetCellFormula("Sheet1", "B1", "A1*100"))
+	a.NoError(f.SetCellValue("Sheet1", "C1", "untouched"))
+
+	f.NewSheet("Rates")
+	a.NoError(f.SetSheetRow("Rates", "B2", &[]interface{}{"Date", "5y", "1 an", "Notes"}))
+	style, err := f.NewStyle(&excelize.Style{NumFmt: 10})
+	a.NoError(err)
+	a.NoError(f.SetCellStyle("Rates", "C3", "C3", style))
+	a.NoError(f.SetDefinedName(&excelize.DefinedName{Name: "OEC_DAILY", RefersTo: "Rates!$B$2:$D$3"}))
+	a.NoError(f.SetDefinedName(&excelize.DefinedName{Name: "OEC_1Y_LATEST", RefersTo: "Rates!$G$1"}))
+	a.NoError(f.SetDefinedName(&excelize.DefinedName{Name: "Other", RefersTo: "Rates!$H$1"}))
+
+	res, err := Fill(f, sources)
+	a.NoError(err)
+	a.Equal(&Result{Placeholders: 5, Cells: 1, Tables: 1, Rows: 3}, res)
+
+	for cell, want := range map[string]string{
+		"A1": "0.035",
+		"A2": "5 ans au 2023-06-08: 0.0350",
+		"A3": "0.046",
+		"A4": "",
+		"C1": "untouched",
+	} {
+		got, _ := f.GetCellValue("Sheet1", cell)
+		a.Equal(want, got, cell)
+	}
+	formula, _ := f.GetCellFormula("Sheet1", "B1")
+	a.Equal("A1*100", formula)
+
+	rows, _ := f.GetRows("Rates", excelize.Options{RawCellValue: true})
+	a.Equal([]string{"", "", "", "", "", "", "0.047"}, rows[0])
+	a.Equal("Notes", rows[1][4])
+	a.Equal([]string{"", "45082", "0.034", "0.045"}, rows[2])
+	a.Equal([]string{"", "45083", "", "0.046"}, rows[3])
+	a.Equal([]string{"", "45085", "0.035", "0.047"}, rows[4])
+	for _, cell := range []string{"C3", "C4", "C5"} {
+		got, _ := f.GetCellStyle("Rates", cell)
+		a.Equal(style, got, cell)
+	}
+	for _, dn := range f.GetDefinedName() {
+		if dn.Name == "OEC_DAILY" {
+			a.Equal("'Rates'!$B$2:$D$5", dn.RefersTo)
+		}
+	}
+}
+
+func Test_FillErrors(t *testing.T) {
+	a := assert.New(t)
+	f := excelize.NewFile()
+	a.NoError(f.SetCellValue("Sheet1", "A1", "{{oec.7y.latest}}"))
+	_, err := Fill(f, sources)
+	a.Error(err, "unknown series")
+
+	f = excelize.NewFile()
+	a.NoError(f.SetCellValue("Sheet1", "A1", "{{oec.5y.avg}}"))
+	_, err = Fill(f, sources)
+	a.Error(err, "unknown field")
+
+	f = excelize.NewFile()
+	a.NoError(f.SetSheetRow("Sheet1", "A1", &[]interface{}{"Date", "7y"}))
+	a.NoError(f.SetDefinedName(&excelize.DefinedName{Name: "OEC_DAILY", RefersTo: "Sheet1!$A$1"}))
+	_, err = Fill(f, sources)
+	a.Error(err, "unknown column")
+}
+
+func Test_parseRef(t *testing.T) {
+	a := assert.New(t)
+	sheet, cell, last, ok := parseRef("'My ''Rates'''!$B$2:$D$5")
+	a.True(ok)
+	a.Equal("My 'Rates'", sheet)
+	a.Equal("B2", cell)
+	a.Equal(4, last)
+	sheet, cell, last, ok = parseRef("Sheet1!A1")
+	a.True(ok)
+	a.Equal("Sheet1", sheet)
+	a.Equal("A1", cell)
+	a.Equal(0, last)
+	_, _, _, ok = parseRef("=SUM(Sheet1!A1:A2)")
+	a.False(ok)
+}